@@ -0,0 +1,127 @@
+package boolean
+
+import (
+	"github.com/jnb666/gogp/gp"
+	"math/bits"
+)
+
+// Bits holds a boolean value for each of a number of fitness cases, packed 64 to a word
+// with case i in bit i%64 of word i/64.
+type Bits []uint64
+
+// NewBits returns a Bits value with space for n cases which are all false.
+func NewBits(n int) Bits {
+	return make(Bits, (n+63)/64)
+}
+
+// Pack returns a Bits value for each of the input variables from a list of fitness cases,
+// where cases[i][j] is the value of input j for case i.
+func Pack(cases [][]bool) []Bits {
+	if len(cases) == 0 {
+		return []Bits{}
+	}
+	inputs := make([]Bits, len(cases[0]))
+	for j := range inputs {
+		inputs[j] = NewBits(len(cases))
+		for i, in := range cases {
+			inputs[j].Set(i, in[j])
+		}
+	}
+	return inputs
+}
+
+// Get returns the value for case i.
+func (b Bits) Get(i int) bool {
+	return b[i/64]>>uint(i%64)&1 == 1
+}
+
+// Set sets the value for case i.
+func (b Bits) Set(i int, val bool) {
+	if val {
+		b[i/64] |= 1 << uint(i%64)
+	} else {
+		b[i/64] &^= 1 << uint(i%64)
+	}
+}
+
+// Matches returns the number of the first n cases where b has the same value as other.
+func (b Bits) Matches(other Bits, n int) int {
+	count := 0
+	for k := range b {
+		match := ^(b[k] ^ other[k])
+		if rem := n - 64*k; rem < 64 {
+			match &= 1<<uint(rem) - 1
+		}
+		count += bits.OnesCount64(match)
+	}
+	return count
+}
+
+// EvalBits evaluates the code for n fitness cases at once, where inputs[j] has the values of
+// input variable j for each case. It gives the same result as calling Expr.Eval for each case.
+// Opcodes which implement WordOp are evaluated 64 cases at a time and boolean constants and
+// variables are expanded directly. Any other opcodes are evaluated one case at a time.
+func EvalBits(code gp.Expr, inputs []Bits, n int) Bits {
+	packed := make([]gp.Value, len(inputs))
+	for j, in := range inputs {
+		packed[j] = in
+	}
+	words := (n + 63) / 64
+	pos := -1
+	var eval func() Bits
+	eval = func() Bits {
+		pos++
+		op := code[pos]
+		arity := op.Arity()
+		if arity == 0 {
+			if val, ok := op.(V); ok {
+				res := NewBits(n)
+				if val {
+					for k := range res {
+						res[k] = ^uint64(0)
+					}
+				}
+				return res
+			}
+			if val, ok := op.Eval(packed...).(Bits); ok {
+				return val
+			}
+			return evalCases(op, nil, inputs, n)
+		}
+		args := make([]Bits, arity)
+		for i := range args {
+			args[i] = eval()
+		}
+		wop, ok := op.(WordOp)
+		if !ok {
+			return evalCases(op, args, inputs, n)
+		}
+		res := make(Bits, words)
+		w := make([]uint64, arity)
+		for k := range res {
+			for i := range w {
+				w[i] = args[i][k]
+			}
+			res[k] = wop.EvalWord(w)
+		}
+		return res
+	}
+	return eval()
+}
+
+// evaluate opcode for one case at a time, with the input values for terminals
+func evalCases(op gp.Opcode, args []Bits, inputs []Bits, n int) Bits {
+	res := NewBits(n)
+	vals := make([]gp.Value, len(args))
+	if len(args) == 0 {
+		vals = make([]gp.Value, len(inputs))
+		args = inputs
+	}
+	for i := 0; i < n; i++ {
+		for j := range vals {
+			vals[j] = V(args[j].Get(i))
+		}
+		res.Set(i, bool(op.Eval(vals...).(V)))
+	}
+	return res
+}
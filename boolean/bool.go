@@ -8,12 +8,43 @@ import (
 var (
 	True  = V(true)
 	False = V(false)
-	And   = Op("and", func(a, b V) V { return a && b })
-	Or    = Op("or", func(a, b V) V { return a || b })
-	Xor   = Op("xor", func(a, b V) V { return (a || b) && !(a && b) })
-	Not   = Unary("not", func(a V) V { return !a })
+	And   = WordFunc(Op("and", func(a, b V) V { return a && b }), func(a []uint64) uint64 { return a[0] & a[1] })
+	Or    = WordFunc(Op("or", func(a, b V) V { return a || b }), func(a []uint64) uint64 { return a[0] | a[1] })
+	Xor   = WordFunc(Op("xor", func(a, b V) V { return (a || b) && !(a && b) }), func(a []uint64) uint64 { return a[0] ^ a[1] })
+	Not   = WordFunc(Unary("not", func(a V) V { return !a }), func(a []uint64) uint64 { return ^a[0] })
+	Nand  = WordFunc(Op("nand", func(a, b V) V { return !(a && b) }), func(a []uint64) uint64 { return ^(a[0] & a[1]) })
+	Nor   = WordFunc(Op("nor", func(a, b V) V { return !(a || b) }), func(a []uint64) uint64 { return ^(a[0] | a[1]) })
+	If    = WordFunc(gp.Formatted(Func("if", 3, if_else), "(%s ? %s : %s)"), func(a []uint64) uint64 { return a[0]&a[1] | ^a[0]&a[2] })
 )
 
+func if_else(a []V) V {
+	if a[0] {
+		return a[1]
+	}
+	return a[2]
+}
+
+// A WordOp is an opcode which can also be evaluated on 64 fitness cases at once, where each
+// bit of the arguments and the result is the value for one case.
+type WordOp interface {
+	gp.Opcode
+	EvalWord(args []uint64) uint64
+}
+
+// WordFunc returns a copy of the opcode which implements the WordOp interface by calling fun.
+func WordFunc(op gp.Opcode, fun func(args []uint64) uint64) WordOp {
+	return wordOp{op, fun}
+}
+
+type wordOp struct {
+	gp.Opcode
+	word func([]uint64) uint64
+}
+
+func (o wordOp) EvalWord(args []uint64) uint64 {
+	return o.word(args)
+}
+
 // V is a boolean value which implements the gp.Opcode interface
 type V bool
 
@@ -32,3 +32,60 @@ func TestEval(t *testing.T) {
 		}
 	}
 }
+
+// test truth table fitness for some known solutions
+func TestTruthTable(t *testing.T) {
+	mux := Multiplexer(2)
+	pset := mux.PrimSet()
+	a0, a1, d0, d1, d2, d3 := pset.Var(0), pset.Var(1), pset.Var(2), pset.Var(3), pset.Var(4), pset.Var(5)
+	maj := Majority(3)
+	tests := []struct {
+		table *TruthTable
+		code  gp.Expr
+		fit   float64
+	}{
+		{mux, gp.Expr{If, a0, If, a1, d3, d2, If, a1, d1, d0}, 1},
+		{mux, gp.Expr{If, a0, d3, d0}, 0.75},
+		{maj, gp.Expr{Or, And, a0, a1, And, d0, Or, a0, a1}, 1},
+		{maj, gp.Expr{Nor, a0, Nand, a1, True}, 0.5},
+		{Parity(2, true), gp.Expr{Not, Xor, a0, a1}, 1},
+		{Parity(7, false), gp.Expr{False}, 0.5},
+	}
+	for _, test := range tests {
+		fit, _ := test.table.Fitness(test.code)
+		t.Logf("%s: %s => %g", test.table, test.code.Format(), fit)
+		if fit != test.fit {
+			t.Errorf("expected fitness %g", test.fit)
+		}
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for too many inputs")
+		}
+	}()
+	Multiplexer(6)
+}
+
+// check bit parallel evaluation gives the same result as Eval, including for an opcode
+// which does not support word operations
+func TestEvalBits(t *testing.T) {
+	gp.SetSeed(1)
+	maj3 := Func("maj3", 3, func(a []V) V { return (a[0] && a[1]) || (a[2] && (a[0] || a[1])) })
+	table := Multiplexer(3)
+	pset := table.PrimSet()
+	pset.Add(And, Or, Xor, Not, Nand, Nor, If, maj3, True, False)
+	for _, ind := range gp.CreatePopulation(100, gp.GenRamped(pset, 1, 6)) {
+		out := table.Eval(ind.Code)
+		for i := 0; i < table.Cases; i++ {
+			in, _ := table.Case(i)
+			if ind.Code.Eval(in...) != V(out.Get(i)) {
+				t.Fatalf("mismatch for case %d: %s", i, ind.Code.Format())
+			}
+		}
+	}
+	cases := [][]bool{{true, false}, {true, true}, {false, false}}
+	out := EvalBits(gp.Expr{Nand, pset.Var(0), pset.Var(1)}, Pack(cases), len(cases))
+	if out.Get(0) != true || out.Get(1) != false || out.Get(2) != true {
+		t.Error("invalid result for packed cases")
+	}
+}
@@ -0,0 +1,137 @@
+package boolean
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+)
+
+// A TruthTable has the target output for every combination of a number of boolean inputs.
+// Case i has the value of input j set to bit (Inputs-1-j) of i, so the first input is the most
+// significant bit. The inputs and outputs are packed into Bits so that all of the cases
+// can be evaluated at once using EvalBits.
+type TruthTable struct {
+	Name   string
+	Names  []string
+	Inputs int
+	Cases  int
+	input  []Bits
+	target Bits
+}
+
+// Maximum number of inputs for a truth table. The number of cases doubles with each input.
+const MaxInputs = 24
+
+// NewTruthTable creates a truth table with the given number of inputs where the target output
+// for each case is given by fun. If names are not given the inputs are named in0, in1 etc.
+// It panics if the number of inputs is less than 1 or more than MaxInputs.
+func NewTruthTable(name string, inputs int, fun func(in []bool) bool, names ...string) *TruthTable {
+	if inputs < 1 || inputs > MaxInputs {
+		panic(fmt.Sprintf("truth table: %d inputs - must be from 1 to %d", inputs, MaxInputs))
+	}
+	t := &TruthTable{Name: name, Names: names, Inputs: inputs, Cases: 1 << uint(inputs)}
+	t.target = NewBits(t.Cases)
+	t.input = make([]Bits, inputs)
+	for j := range t.input {
+		t.input[j] = NewBits(t.Cases)
+	}
+	in := make([]bool, inputs)
+	for i := 0; i < t.Cases; i++ {
+		for j := range in {
+			in[j] = i>>uint(inputs-1-j)&1 == 1
+			t.input[j].Set(i, in[j])
+		}
+		t.target.Set(i, fun(in))
+	}
+	return t
+}
+
+// String returns the name and size of the truth table.
+func (t *TruthTable) String() string {
+	return fmt.Sprintf("%s(%d inputs, %d cases)", t.Name, t.Inputs, t.Cases)
+}
+
+// PrimSet returns a new primitive set with a variable for each of the inputs.
+func (t *TruthTable) PrimSet() *gp.PrimSet {
+	return gp.CreatePrimSet(t.Inputs, t.Names...)
+}
+
+// Case returns the input values and target output for case number i.
+func (t *TruthTable) Case(i int) (in []gp.Value, out V) {
+	in = make([]gp.Value, t.Inputs)
+	for j := range in {
+		in[j] = V(t.input[j].Get(i))
+	}
+	return in, V(t.target.Get(i))
+}
+
+// Eval returns the output of the code for every case.
+func (t *TruthTable) Eval(code gp.Expr) Bits {
+	return EvalBits(code, t.input, t.Cases)
+}
+
+// Correct returns the number of cases for which the output of the code matches the target.
+func (t *TruthTable) Correct(code gp.Expr) int {
+	return t.Eval(code).Matches(t.target, t.Cases)
+}
+
+// Fitness returns the fraction of cases which are correct. It can be used as the Fitness
+// function in a gp.Model.
+func (t *TruthTable) Fitness(code gp.Expr) (float64, bool) {
+	return float64(t.Correct(code)) / float64(t.Cases), true
+}
+
+// Parity returns the truth table for the n input even parity problem if even is set, which is
+// true if an even number of inputs are set, else the odd parity problem.
+func Parity(n int, even bool) *TruthTable {
+	name := "OddParity"
+	if even {
+		name = "EvenParity"
+	}
+	return NewTruthTable(name, n, func(in []bool) bool {
+		parity := even
+		for _, bit := range in {
+			if bit {
+				parity = !parity
+			}
+		}
+		return parity
+	})
+}
+
+// Majority returns the truth table for the n input majority problem, which is true if more
+// than half of the inputs are set.
+func Majority(n int) *TruthTable {
+	return NewTruthTable("Majority", n, func(in []bool) bool {
+		count := 0
+		for _, bit := range in {
+			if bit {
+				count++
+			}
+		}
+		return 2*count > n
+	})
+}
+
+// Multiplexer returns the truth table for the multiplexer problem with k address inputs
+// named a0..ak-1 and 2**k data inputs named d0..dn-1. The output is the value of the data input
+// selected by the address, e.g. Multiplexer(3) is the 11-multiplexer.
+func Multiplexer(k int) *TruthTable {
+	n := 1 << uint(k)
+	names := make([]string, k+n)
+	for i := 0; i < k; i++ {
+		names[i] = fmt.Sprintf("a%d", i)
+	}
+	for i := 0; i < n; i++ {
+		names[k+i] = fmt.Sprintf("d%d", i)
+	}
+	return NewTruthTable("Multiplexer", k+n, func(in []bool) bool {
+		addr := 0
+		for i := 0; i < k; i++ {
+			addr <<= 1
+			if in[i] {
+				addr |= 1
+			}
+		}
+		return in[k+addr]
+	}, names...)
+}
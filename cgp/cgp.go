@@ -0,0 +1,310 @@
+// Package cgp provides cartesian genetic programming for gogp. A genome is a fixed length row of
+// nodes, each of which has an opcode from the primitive set and a connection gene for each
+// argument which links to an input or to an earlier node. The output gene selects the node which
+// gives the result. Only the active nodes connected to the output are evaluated. Genomes
+// implement the gp.Program interface so the standard selection, logging and stats can be used.
+package cgp
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"math/rand"
+	"strings"
+)
+
+// A Grid defines the shape of the genome for a run. Nodes use the primitives and any terminals
+// other than the input variables from the primitive set. Columns is the number of nodes and
+// LevelsBack is the maximum distance back along the row for a connection, or zero for no limit.
+type Grid struct {
+	PrimSet    *gp.PrimSet
+	Columns    int
+	LevelsBack int
+	funcs      []gp.Opcode
+}
+
+// NewGrid creates a new grid definition.
+func NewGrid(pset *gp.PrimSet, columns, levelsBack int) *Grid {
+	funcs := append(append([]gp.Opcode{}, pset.Primitives...), pset.Terminals[pset.NumVars:]...)
+	return &Grid{pset, columns, levelsBack, funcs}
+}
+
+// String returns the grid parameters.
+func (g *Grid) String() string {
+	return fmt.Sprintf("Grid(columns=%d, levels=%d)", g.Columns, g.LevelsBack)
+}
+
+// a node applies op to the values from the in connections, which are numbered with the
+// inputs first followed by the nodes.
+type node struct {
+	op gp.Opcode
+	in []int
+}
+
+// genome implements the gp.Program interface
+type genome struct {
+	g      *Grid
+	nodes  []node
+	out    int
+	active []bool
+}
+
+// create a new genome and flag the nodes which are connected to the output
+func newGenome(g *Grid, nodes []node, out int) *genome {
+	nvars := g.PrimSet.NumVars
+	c := &genome{g: g, nodes: nodes, out: out, active: make([]bool, len(nodes))}
+	if out >= nvars {
+		c.active[out-nvars] = true
+	}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if c.active[i] {
+			for _, in := range nodes[i].in {
+				if in >= nvars {
+					c.active[in-nvars] = true
+				}
+			}
+		}
+	}
+	return c
+}
+
+// get the genome from the code for an individual, ok is false if it is some other expression
+func getGenome(code gp.Expr) (c *genome, ok bool) {
+	if len(code) == 1 {
+		c, ok = code[0].(*genome)
+	}
+	return
+}
+
+// returns a copy of the nodes which can be modified
+func (c *genome) clone() []node {
+	nodes := make([]node, len(c.nodes))
+	for i, n := range c.nodes {
+		nodes[i] = node{n.op, append([]int{}, n.in...)}
+	}
+	return nodes
+}
+
+func (c *genome) Arity() int { return 0 }
+
+// Eval evaluates the active nodes with the given input values and returns the output.
+func (c *genome) Eval(input ...gp.Value) gp.Value {
+	nvars := c.g.PrimSet.NumVars
+	values := make([]gp.Value, nvars+len(c.nodes))
+	copy(values, input)
+	for i, n := range c.nodes {
+		if c.active[i] {
+			args := make([]gp.Value, len(n.in))
+			for j, in := range n.in {
+				args[j] = values[in]
+			}
+			values[nvars+i] = n.op.Eval(args...)
+		}
+	}
+	return values[c.out]
+}
+
+// name of input or node n
+func (c *genome) name(n int) string {
+	nvars := c.g.PrimSet.NumVars
+	if n < nvars {
+		return c.g.PrimSet.Var(n).String()
+	}
+	if op := c.nodes[n-nvars].op; op.Arity() == 0 {
+		return op.Format()
+	}
+	return fmt.Sprintf("n%d", n-nvars)
+}
+
+// Format returns the active nodes separated by semicolons. The last node is the output.
+func (c *genome) Format(args ...string) string {
+	list := []string{}
+	for i, n := range c.nodes {
+		if c.active[i] && n.op.Arity() > 0 {
+			names := make([]string, len(n.in))
+			for j, in := range n.in {
+				names[j] = c.name(in)
+			}
+			list = append(list, fmt.Sprintf("n%d = %s", i, n.op.Format(names...)))
+		}
+	}
+	if len(list) == 0 {
+		return c.name(c.out)
+	}
+	return strings.Join(list, "; ")
+}
+
+func (c *genome) String() string { return c.Format() }
+
+// Size returns the number of active nodes.
+func (c *genome) Size() int {
+	count := 0
+	for _, active := range c.active {
+		if active {
+			count++
+		}
+	}
+	return count
+}
+
+// Depth returns the length of the longest path from an input to the output.
+func (c *genome) Depth() int {
+	nvars := c.g.PrimSet.NumVars
+	depth := make([]int, nvars+len(c.nodes))
+	for i, n := range c.nodes {
+		if c.active[i] && n.op.Arity() > 0 {
+			max := 0
+			for _, in := range n.in {
+				if depth[in] > max {
+					max = depth[in]
+				}
+			}
+			depth[nvars+i] = max + 1
+		}
+	}
+	return depth[c.out]
+}
+
+// random connection for node i
+func (g *Grid) connection(i int) int {
+	start := 0
+	if g.LevelsBack > 0 && i > g.LevelsBack {
+		start = i - g.LevelsBack
+	}
+	n := rand.Intn(g.PrimSet.NumVars + i - start)
+	if n < g.PrimSet.NumVars {
+		return n
+	}
+	return n + start
+}
+
+// random opcode
+func (g *Grid) function() gp.Opcode {
+	op := g.funcs[rand.Intn(len(g.funcs))]
+	if erc, ok := op.(gp.EphemeralConstant); ok {
+		return erc.Init()
+	}
+	return op
+}
+
+// set node i to a new random function, keeping existing connections where possible
+func (g *Grid) setFunction(n *node, i int) {
+	n.op = g.function()
+	for len(n.in) < n.op.Arity() {
+		n.in = append(n.in, g.connection(i))
+	}
+	n.in = n.in[:n.op.Arity()]
+}
+
+type generator struct{ g *Grid }
+
+// Gen returns a generator which produces random genomes.
+func (g *Grid) Gen() gp.Generator {
+	return generator{g}
+}
+
+func (gen generator) String() string {
+	return fmt.Sprintf("CgpGen(%d,%d)", gen.g.Columns, gen.g.LevelsBack)
+}
+
+func (gen generator) Generate() *gp.Individual {
+	g := gen.g
+	nodes := make([]node, g.Columns)
+	for i := range nodes {
+		g.setFunction(&nodes[i], i)
+	}
+	ind := gp.Create(gp.Expr{newGenome(g, nodes, rand.Intn(g.PrimSet.NumVars+g.Columns))})
+	ind.Op = gp.Init
+	return ind
+}
+
+// mutate gene number i, where genes are numbered with the function and connection genes for
+// each node followed by the output gene. Returns the node number, or -1 for the output gene.
+func (g *Grid) mutate(nodes []node, out *int, gene int) int {
+	for i := range nodes {
+		if gene == 0 {
+			g.setFunction(&nodes[i], i)
+			return i
+		}
+		gene--
+		if gene < len(nodes[i].in) {
+			nodes[i].in[gene] = g.connection(i)
+			return i
+		}
+		gene -= len(nodes[i].in)
+	}
+	*out = rand.Intn(g.PrimSet.NumVars + len(nodes))
+	return -1
+}
+
+// count the genes in the genome
+func genes(nodes []node) int {
+	count := 1
+	for _, n := range nodes {
+		count += 1 + len(n.in)
+	}
+	return count
+}
+
+// MutPoint returns a mutation variation where each gene is changed with probability rate.
+// At least one gene is always changed.
+func (g *Grid) MutPoint(rate float64) gp.Variation {
+	return gp.NewVariation(fmt.Sprintf("CgpMutPoint(%g)", rate), func(in gp.Population) gp.Population {
+		c, ok := getGenome(in[0].Code)
+		if !ok {
+			return in
+		}
+		nodes, out := c.clone(), c.out
+		count := genes(nodes)
+		mutated := false
+		for gene := 0; gene < count; gene++ {
+			if rand.Float64() < rate {
+				g.mutate(nodes, &out, gene)
+				mutated = true
+			}
+		}
+		if !mutated {
+			g.mutate(nodes, &out, rand.Intn(count))
+		}
+		in[0] = gp.Create(gp.Expr{newGenome(g, nodes, out)})
+		return in
+	})
+}
+
+// MutActive returns a mutation variation which changes random genes until a gene of one of the
+// active nodes, or the output gene, has been changed.
+func (g *Grid) MutActive() gp.Variation {
+	return gp.NewVariation("CgpMutActive", func(in gp.Population) gp.Population {
+		c, ok := getGenome(in[0].Code)
+		if !ok {
+			return in
+		}
+		nodes, out := c.clone(), c.out
+		for {
+			n := g.mutate(nodes, &out, rand.Intn(genes(nodes)))
+			if n < 0 || c.active[n] {
+				break
+			}
+		}
+		in[0] = gp.Create(gp.Expr{newGenome(g, nodes, out)})
+		return in
+	})
+}
+
+// Crossover returns a crossover variation which exchanges the nodes after a random point in the
+// row between two genomes. Each child keeps the output gene from its first parent.
+func (g *Grid) Crossover() gp.Variation {
+	return gp.NewVariation("CgpCx", func(in gp.Population) gp.Population {
+		c1, ok1 := getGenome(in[0].Code)
+		c2, ok2 := getGenome(in[1].Code)
+		if !ok1 || !ok2 || len(c1.nodes) < 2 {
+			return in
+		}
+		pos := 1 + rand.Intn(len(c1.nodes)-1)
+		nodes1, nodes2 := c1.clone(), c2.clone()
+		child1 := append(nodes1[:pos:pos], nodes2[pos:]...)
+		child2 := append(nodes2[:pos:pos], nodes1[pos:]...)
+		in[0] = gp.Create(gp.Expr{newGenome(g, child1, c1.out)})
+		in[1] = gp.Create(gp.Expr{newGenome(g, child2, c2.out)})
+		return in
+	})
+}
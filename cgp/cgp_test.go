@@ -0,0 +1,77 @@
+package cgp
+
+import (
+	"github.com/jnb666/gogp/boolean"
+	"github.com/jnb666/gogp/gp"
+	"testing"
+)
+
+func setup() *Grid {
+	pset := gp.CreatePrimSet(2, "a", "b")
+	pset.Add(boolean.And, boolean.Or, boolean.Not, boolean.True)
+	return NewGrid(pset, 10, 4)
+}
+
+// test evaluating and formatting a genome
+func TestEval(t *testing.T) {
+	g := setup()
+	// inputs are a,b then nodes n0..n4
+	nodes := []node{
+		{boolean.Or, []int{0, 1}},  // n0 = a | b
+		{boolean.And, []int{0, 1}}, // n1 = a & b
+		{boolean.True, []int{}},    // n2 = true - unused
+		{boolean.Not, []int{3}},    // n3 = !(a & b)
+		{boolean.And, []int{2, 5}}, // n4 = n0 & n3
+	}
+	c := newGenome(g, nodes, 6)
+	ind := gp.Create(gp.Expr{c})
+	format := ind.Code.Format()
+	t.Log(format)
+	if format != "n0 = (a or b); n1 = (a and b); n3 = not(n1); n4 = (n0 and n3)" {
+		t.Error("invalid format")
+	}
+	for _, in := range [][2]boolean.V{{false, false}, {false, true}, {true, false}, {true, true}} {
+		if ind.Code.Eval(in[0], in[1]) != in[0] != in[1] {
+			t.Errorf("invalid result for %v", in)
+		}
+	}
+	if ind.Size() != 4 || ind.Depth() != 3 {
+		t.Errorf("expecting size 4 depth 3, got %d %d", ind.Size(), ind.Depth())
+	}
+}
+
+// test the variation operators only produce valid connections
+func TestVariation(t *testing.T) {
+	gp.SetSeed(1)
+	g := setup()
+	eval := &gp.Model{Fitness: func(code gp.Expr) (float64, bool) { return 0, true }}
+	pop := gp.CreatePopulation(50, g.Gen())
+	for i := 0; i < 20; i++ {
+		pop = gp.VarAnd(pop, g.Crossover(), g.MutPoint(0.05), 0.5, 0.5)
+		pop, _ = gp.VarAnd(pop, g.Crossover(), g.MutActive(), 0.5, 0.5).Evaluate(eval, 1)
+	}
+	for _, ind := range pop {
+		c := ind.Code[0].(*genome)
+		for i, n := range c.nodes {
+			if len(n.in) != n.op.Arity() {
+				t.Fatalf("node %d has %d connections for %s", i, len(n.in), n.op)
+			}
+			for _, in := range n.in {
+				if in >= i+2 || in < i+2-g.LevelsBack && in >= 2 {
+					t.Fatalf("invalid connection %d for node %d", in, i)
+				}
+			}
+		}
+		if _, ok := ind.Code.Eval(boolean.True, boolean.False).(boolean.V); !ok {
+			t.Fatalf("error evaluating %s", ind.Code.Format())
+		}
+	}
+	t.Log(pop[0].Code.Format())
+	// code which is not a genome is returned unchanged
+	tree := gp.Create(gp.Expr{boolean.Not, boolean.True})
+	for _, v := range []gp.Variation{g.Crossover(), g.MutPoint(0.05), g.MutActive()} {
+		if out := v.Variate(gp.Population{tree, pop[0]}); out[0].Id != tree.Id {
+			t.Errorf("%s: expected tree to be unchanged", v)
+		}
+	}
+}
@@ -8,193 +8,95 @@ import (
 	"github.com/jnb666/gogp/gp"
 	"github.com/jnb666/gogp/stats"
 	"github.com/jnb666/gogp/util"
+	"github.com/jnb666/gogp/world"
 )
 
+// cell values
 const (
-	FOOD  = '#'
-	TRAIL = '*'
-	START = 'S'
+	FOOD  = 1
+	TRAIL = 2
 )
 
-// grid of cells
-type Grid [][]byte
-
 // global config data
 type Config struct {
-	startRow, startCol, startDir int
-	maxMoves, totalFood          int
-	plotRows, plotCols           int
-	grid                         Grid
-}
-
-// ant data
-type Ant struct {
-	row, col, dir          int
-	maxMoves, moves, eaten int
-	grid                   Grid
-	path                   [][2]int
-}
-
-// execute each of args in sequence
-type progN struct{ *gp.BaseFunc }
-
-func (o progN) Eval(args ...gp.Value) gp.Value {
-	return func(ant *Ant) {
-		for _, arg := range args {
-			arg.(func(*Ant))(ant)
-		}
-	}
-}
-
-// terminal node
-type terminal struct {
-	*gp.BaseFunc
-	fn func(*Ant)
-}
-
-func Terminal(name string, fn func(*Ant)) gp.Opcode {
-	return terminal{&gp.BaseFunc{name, 0}, fn}
-}
-
-func (o terminal) Eval(args ...gp.Value) gp.Value {
-	return o.fn
-}
-
-// turn left or left
-func turn(dir int) func(*Ant) {
-	return func(ant *Ant) {
-		if ant.moves < ant.maxMoves {
-			ant.moves++
-			ant.dir = util.Mod(ant.dir+dir, 4)
-			ant.grid[ant.row][ant.col] = TRAIL
-		}
-	}
-}
-
-// step forward and pick up food if in new cell
-func step(ant *Ant) {
-	if ant.moves < ant.maxMoves {
-		ant.moves++
-		ant.row, ant.col = ant.grid.Next(ant.row, ant.col, ant.dir)
-		if ant.grid[ant.row][ant.col] == FOOD {
-			ant.eaten++
-		}
-		ant.grid[ant.row][ant.col] = TRAIL
-		ant.path = append(ant.path, [2]int{ant.col, ant.row})
-	}
-}
-
-// if next cell contains food execute first arg else execute second
-type ifFood struct{ *gp.BaseFunc }
-
-func (o ifFood) Eval(args ...gp.Value) gp.Value {
-	return func(ant *Ant) {
-		row, col := ant.grid.Next(ant.row, ant.col, ant.dir)
-		if ant.grid[row][col] == FOOD {
-			args[0].(func(*Ant))(ant)
-		} else {
-			args[1].(func(*Ant))(ant)
-		}
-	}
-}
-
-// grid methods
-func (g Grid) Next(row, col, dir int) (nRow, nCol int) {
-	rows, cols := len(g), len(g[0])
-	nRow = util.Mod(row+[]int{1, 0, -1, 0}[dir], rows)
-	nCol = util.Mod(col+[]int{0, 1, 0, -1}[dir], cols)
-	return
-}
-
-func (g Grid) String() (text string) {
-	for _, line := range g {
-		text += string(line) + "\n"
-	}
-	return
-}
-
-func (g Grid) Clone() Grid {
-	grid := make(Grid, len(g))
-	for row, line := range g {
-		grid[row] = append([]byte{}, line...)
-	}
-	return grid
+	plotRows, plotCols int
+	totalFood          int
+	grid               *world.Grid
 }
 
 // read the trail file to setup the grid
 func readTrail(file string) *Config {
 	s := util.Open(file)
-	conf := Config{grid: Grid{}}
+	conf := Config{}
 	// first line has max no. of moves and plot dimensions
-	util.Read(s, &conf.maxMoves, &conf.plotRows, &conf.plotCols)
-	fmt.Println("max moves =", conf.maxMoves, "plot size =", conf.plotRows, conf.plotCols)
+	var maxMoves int
+	util.Read(s, &maxMoves, &conf.plotRows, &conf.plotCols)
+	fmt.Println("max moves =", maxMoves, "plot size =", conf.plotRows, conf.plotCols)
 	// read the grid
-	row := 0
-	for s.Scan() {
-		line := s.Bytes()
-		for col, cell := range line {
-			switch cell {
-			case FOOD:
-				conf.totalFood++
-			case START:
-				conf.startRow, conf.startCol = row, col
-				conf.startDir = 1
-				line[col] = TRAIL
-			}
+	conf.grid = world.Read(s, maxMoves, func(ch byte) (int, bool) {
+		switch ch {
+		case '#':
+			return FOOD, false
+		case 'S':
+			return TRAIL, true
 		}
-		copy := append([]byte{}, line...)
-		conf.grid = append(conf.grid, copy)
-		row++
+		return world.Empty, false
+	})
+	conf.totalFood = conf.grid.Count(FOOD)
+	// eat any food in each new cell
+	conf.grid.Enter = func(ant *world.Agent) {
+		if ant.Here() == FOOD {
+			ant.Score++
+		}
+		ant.World().Set(ant.Row, ant.Col, TRAIL)
 	}
 	return &conf
 }
 
-// create a new ant - make deep copy of grid
-func newAnt(conf *Config) *Ant {
-	grid := conf.grid.Clone()
-	path := make([][2]int, 1, conf.maxMoves)
-	path[0][0], path[0][1] = conf.startCol, conf.startRow
-	return &Ant{
-		maxMoves: conf.maxMoves,
-		row:      conf.startRow,
-		col:      conf.startCol,
-		dir:      conf.startDir,
-		grid:     grid,
-		path:     path,
-	}
-}
-
 // run the code
-func run(conf *Config, code gp.Expr) *Ant {
-	ant := newAnt(conf)
-	runFunc := code.Eval().(func(*Ant))
-	for ant.moves < ant.maxMoves {
-		runFunc(ant)
-	}
-	return ant
+func run(conf *Config, code gp.Expr, record bool) *world.World {
+	w := conf.grid.New(record)
+	w.Run(code.Eval().(world.Action), 0)
+	return w
 }
 
 // run the program to calculate the fitness as no. of food cells eaten / total
 func fitnessFunc(conf *Config) func(gp.Expr) (float64, bool) {
 	return func(code gp.Expr) (float64, bool) {
-		ant := run(conf, code)
-		return float64(ant.eaten) / float64(conf.totalFood), true
+		w := run(conf, code, false)
+		return float64(w.Score()) / float64(conf.totalFood), true
+	}
+}
+
+// run the program to calculate the fitness and the behaviour, which is the position of the ant
+// at a number of points along its path
+func behaviourFunc(conf *Config, points int) func(gp.Expr) (float64, bool, []float64) {
+	return func(code gp.Expr) (float64, bool, []float64) {
+		w := run(conf, code, true)
+		path := w.Agents[0].Path
+		behaviour := make([]float64, 0, 2*points)
+		for i := 1; i <= points; i++ {
+			pos := path[i*(len(path)-1)/points]
+			behaviour = append(behaviour, float64(pos[0]), float64(pos[1]))
+		}
+		return float64(w.Score()) / float64(conf.totalFood), true, behaviour
 	}
 }
 
 // returns function to plot path of best individual
 func createPlot(c *Config, size, delay int) func(gp.Population) []byte {
 	sz := size / c.plotCols
+	start := c.grid.Start[0]
 	return func(pop gp.Population) []byte {
 		ch := make(chan [][2]int)
 		go func() {
-			ant := run(c, pop.Best().Code)
-			ch <- ant.path
+			w := run(c, pop.Best().Code, true)
+			ch <- w.Agents[0].Path
 		}()
 		// draw grid
 		plot := util.SVGPlot(size, size, sz)
 		plot.AddGrid(c.plotCols, c.plotRows, delay, func(x, y int) string {
-			if c.grid[y][x] == FOOD {
+			if c.grid.Cells[y][x] == FOOD {
 				return "fill:green"
 			} else {
 				return "fill:grey"
@@ -202,7 +104,7 @@ func createPlot(c *Config, size, delay int) func(gp.Population) []byte {
 		})
 		// draw ant
 		plot.Gid("ant")
-		plot.Circle(c.startCol*sz+sz/2, c.startRow*sz+sz/2, int(0.4*float64(sz)), "fill:black")
+		plot.Circle(start.Col*sz+sz/2, start.Row*sz+sz/2, int(0.4*float64(sz)), "fill:black")
 		plot.Gend()
 		plot.Animate("ant", <-ch,
 			map[string]string{"fill:grey": "fill:brown", "fill:green": "fill:red"})
@@ -213,23 +115,22 @@ func createPlot(c *Config, size, delay int) func(gp.Population) []byte {
 // build and run model
 func main() {
 	// get options
-	var maxSize, maxDepth int
+	var maxSize, maxDepth, neighbours int
+	var novelty float64
 	var trailFile string
 	flag.IntVar(&maxSize, "size", 0, "maximum tree size - zero for none")
 	flag.IntVar(&maxDepth, "depth", 0, "maximum tree depth - zero for none")
 	flag.StringVar(&trailFile, "trail", "santafe_trail.txt", "trail definition file")
+	flag.Float64Var(&novelty, "novelty", 0, "weight for novelty search from 0 to 1 - zero for fitness only")
+	flag.IntVar(&neighbours, "neighbours", 15, "no. of nearest neighbours for novelty search")
 	opts := util.DefaultOptions
 	util.ParseFlags(&opts)
 
 	// create primitive set
 	config := readTrail(trailFile)
 	pset := gp.CreatePrimSet(0)
-	pset.Add(progN{&gp.BaseFunc{"prog2", 2}})
-	pset.Add(progN{&gp.BaseFunc{"prog3", 3}})
-	pset.Add(ifFood{&gp.BaseFunc{"if_food", 2}})
-	pset.Add(Terminal("left", turn(-1)))
-	pset.Add(Terminal("right", turn(1)))
-	pset.Add(Terminal("step", step))
+	pset.Add(world.Prog2, world.Prog3, world.IfAhead("if_food", FOOD))
+	pset.Add(world.Left, world.Right, world.Step)
 
 	// setup model
 	problem := &gp.Model{
@@ -244,6 +145,12 @@ func main() {
 		CrossoverProb: opts.CrossoverProb,
 		Threads:       opts.Threads,
 	}
+	var archive *gp.Archive
+	if novelty > 0 {
+		archive = gp.NewArchive(neighbours, 10, 2000)
+		problem.Behaviour = behaviourFunc(config, 10)
+		problem.Offspring = gp.NoveltySelect(problem.Offspring, archive, novelty)
+	}
 	if maxDepth > 0 {
 		problem.AddDecorator(gp.DepthLimit(maxDepth))
 	}
@@ -253,17 +160,24 @@ func main() {
 	problem.PrintParams("== Artificial ant ==")
 
 	logger := stats.NewLogger(opts.MaxGen, opts.TargetFitness)
+	logger.Name = trailFile
+	if archive != nil {
+		logger.Archive = archive
+		stats.LogColumn = append(stats.LogColumn, "Novelty.Avg", "Archive")
+	}
 	if opts.Verbose {
 		logger.OnDone = func(best *gp.Individual) {
-			ant := run(config, best.Code)
-			fmt.Println(ant.grid)
+			w := run(config, best.Code, false)
+			fmt.Print(w.Format(func(value int, ant bool) byte { return ".#*"[value] }))
 		}
 	}
 
 	// run
 	if opts.Plot {
 		logger.RegisterSVGPlot("best", createPlot(config, 500, 10))
-		stats.MainLoop(problem, logger, ":8080", "../web")
+		stats.Headless = opts.Headless
+		logger.Interactive = opts.Step
+		stats.MainLoop(problem, logger, opts.Port, "../web")
 	} else {
 		fmt.Println()
 		logger.PrintStats = true
@@ -2,64 +2,52 @@ package main
 
 // Boolean even parity problem
 // aim is to generate a function which will return the even parity bit for PARITY_FANIN boolean inputs
+// use -problem to select the multiplexer or majority problems instead, where the default is the
+// 11-multiplexer with MUX_FANIN address inputs or MAJORITY_FANIN inputs
 
 import (
+	"flag"
 	"fmt"
 	"github.com/jnb666/gogp/boolean"
 	"github.com/jnb666/gogp/gp"
 	"github.com/jnb666/gogp/stats"
 	"github.com/jnb666/gogp/util"
-	"math"
 )
 
 const PARITY_FANIN = 6
-const FORMAT = "%06b"
+const MUX_FANIN = 3
+const MAJORITY_FANIN = 6
 const TARGET = 0.99
 
-// check each of the 2**PARITY_FANIN cases to get parity at initialisation time
-func getFitnessFunc() func(gp.Expr) (float64, bool) {
-	paritySize := int(math.Pow(2, PARITY_FANIN))
-	input := make([][]gp.Value, paritySize)
-	output := make([]gp.Value, paritySize)
-	for i := range output {
-		input[i] = make([]gp.Value, PARITY_FANIN)
-		bitstr := fmt.Sprintf(FORMAT, i)
-		parity := true
-		for j, bit := range bitstr {
-			if bit == '1' {
-				input[i][j] = boolean.True
-				parity = !parity
-			} else {
-				input[i][j] = boolean.False
-			}
-		}
-		output[i] = boolean.V(parity)
-	}
-	// fitness is no. of correct cases / total
-	return func(code gp.Expr) (float64, bool) {
-		correct := 0
-		for i, in := range input {
-			if code.Eval(in...) == output[i] {
-				correct++
-			}
-		}
-		return float64(correct) / float64(paritySize), true
-	}
-}
-
 // main GP routine
 func main() {
+	var problemName string
+	var fanin int
+	flag.StringVar(&problemName, "problem", "parity", "problem to solve: parity, mux or majority")
+	flag.IntVar(&fanin, "fanin", 0, "no. of inputs, or address inputs for mux (default depends on problem)")
 	opts := util.DefaultOptions
 	util.ParseFlags(&opts)
 
-	pset := gp.CreatePrimSet(PARITY_FANIN)
+	var table *boolean.TruthTable
+	switch problemName {
+	case "mux":
+		table = boolean.Multiplexer(defaultFanin(fanin, MUX_FANIN))
+	case "majority":
+		table = boolean.Majority(defaultFanin(fanin, MAJORITY_FANIN))
+	default:
+		table = boolean.Parity(defaultFanin(fanin, PARITY_FANIN), true)
+	}
+	pset := table.PrimSet()
 	pset.Add(boolean.And, boolean.Or, boolean.Xor, boolean.Not, boolean.True, boolean.False)
+	if problemName == "mux" {
+		pset.Add(boolean.If)
+	}
 
 	problem := &gp.Model{
 		PrimitiveSet:  pset,
 		Generator:     gp.GenFull(pset, 3, 5),
 		PopSize:       opts.PopSize,
-		Fitness:       getFitnessFunc(),
+		Fitness:       table.Fitness,
 		Offspring:     gp.Tournament(opts.TournSize),
 		Mutate:        gp.MutUniform(gp.GenGrow(pset, 0, 2)),
 		MutateProb:    opts.MutateProb,
@@ -67,11 +55,14 @@ func main() {
 		CrossoverProb: opts.CrossoverProb,
 		Threads:       opts.Threads,
 	}
-	problem.PrintParams("== Even parity problem for", PARITY_FANIN, "inputs ==")
+	problem.PrintParams("==", table, "==")
 
 	logger := stats.NewLogger(opts.MaxGen, opts.TargetFitness)
+	logger.Name = fmt.Sprintf("%s%d", table.Name, table.Inputs)
 	if opts.Plot {
-		stats.MainLoop(problem, logger, ":8080", "../web")
+		stats.Headless = opts.Headless
+		logger.Interactive = opts.Step
+		stats.MainLoop(problem, logger, opts.Port, "../web")
 	} else {
 		fmt.Println()
 		logger.PrintStats = true
@@ -79,3 +70,11 @@ func main() {
 		problem.Run(logger)
 	}
 }
+
+// use the default for the problem if fanin is not set
+func defaultFanin(fanin, def int) int {
+	if fanin <= 0 {
+		return def
+	}
+	return fanin
+}
@@ -7,23 +7,26 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/jnb666/gogp/cgp"
 	"github.com/jnb666/gogp/gp"
+	"github.com/jnb666/gogp/grammar"
+	"github.com/jnb666/gogp/lgp"
 	"github.com/jnb666/gogp/num"
 	"github.com/jnb666/gogp/stats"
 	"github.com/jnb666/gogp/util"
+	"io/ioutil"
 	"math/rand"
+	"os"
 )
 
-type Point struct{ x, y float64 }
-
 // read data file
-func getData(filename string) (ERCmin, ERCmax int, trainSet []Point) {
+func getData(filename string) (ERCmin, ERCmax int, data *num.Dataset) {
 	s := util.Open(filename)
 	util.Read(s, &ERCmin, &ERCmax)
-	trainSet = []Point{}
-	var p Point
-	for util.Read(s, &p.x, &p.y) {
-		trainSet = append(trainSet, p)
+	data = num.NewDataset("x")
+	var x, y float64
+	for util.Read(s, &x, &y) {
+		data.Add(y, x)
 	}
 	return
 }
@@ -36,59 +39,155 @@ func ercGen(start, end int) func() num.V {
 	}
 }
 
-// returns function to calc least squares difference and return as normalised fitness from 0->1
-func fitnessFunc(trainSet []Point) func(gp.Expr) (float64, bool) {
-	return func(code gp.Expr) (float64, bool) {
-		diff := 0.0
-		for _, pt := range trainSet {
-			val := float64(code.Eval(num.V(pt.x)).(num.V))
-			diff += (val - pt.y) * (val - pt.y)
-		}
-		return 1.0 / (1.0 + diff), true
+// x axis for plots is the input value if there is only one, else the case number
+func xValue(i int, inputs []gp.Value) float64 {
+	if len(inputs) == 1 {
+		return float64(inputs[0].(num.V))
 	}
+	return float64(i)
 }
 
 // function to plot target curve
-func plotTarget(trainSet []Point) func(gp.Population) stats.Plot {
+func plotTarget(data *num.Dataset) func(gp.Population) stats.Plot {
 	return func(pop gp.Population) stats.Plot {
-		plot := stats.NewPlot("Target", len(trainSet))
+		plot := stats.NewPlot("Target", data.Len())
 		plot.Color = "#00ff00"
-		for i, pt := range trainSet {
-			plot.Data[i][0], plot.Data[i][1] = pt.x, pt.y
+		for i, in := range data.Inputs {
+			plot.Data[i][0], plot.Data[i][1] = xValue(i, in), data.Target[i]
 		}
 		return plot
 	}
 }
 
 // function to plot best individual
-func plotBest(trainSet []Point) func(gp.Population) stats.Plot {
+func plotBest(data *num.Dataset) func(gp.Population) stats.Plot {
 	return func(pop gp.Population) stats.Plot {
-		plot := stats.NewPlot("Best", len(trainSet))
+		plot := stats.NewPlot("Best", data.Len())
 		plot.Color = "#ff0000"
-		code := pop.Best().Code
-		for i, pt := range trainSet {
-			plot.Data[i][0] = pt.x
-			plot.Data[i][1] = float64(code.Eval(num.V(pt.x)).(num.V))
+		for i, val := range data.Outputs(pop.Best().Code) {
+			plot.Data[i][0], plot.Data[i][1] = xValue(i, data.Inputs[i]), val
 		}
 		return plot
 	}
 }
 
+// read grammar from file using opcodes from the primitive set
+func readGrammar(file string, pset *gp.PrimSet) *grammar.Grammar {
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Println("error opening grammar file:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	g, err := grammar.Read(f, pset)
+	if err != nil {
+		fmt.Println("error reading grammar", file, err)
+		os.Exit(1)
+	}
+	return g
+}
+
+// write the MAP-Elites archive to a JSON file
+func writeElites(e *gp.Elites, file string) {
+	f, err := os.Create(file)
+	if err != nil {
+		fmt.Println("error creating elites file:", err)
+		return
+	}
+	defer f.Close()
+	if err = e.WriteJSON(f); err != nil {
+		fmt.Println("error writing elites file:", err)
+		return
+	}
+	fmt.Printf("wrote %d elites to %s\n", e.Len(), file)
+}
+
+// returns function to write the ancestry graph for the best individual and print operator summary
+func writeAncestry(g *gp.Genealogy, file string) func(*gp.Individual) {
+	return func(best *gp.Individual) {
+		for op, sum := range g.Summary(best.Id, 0) {
+			fmt.Printf("%-40s count=%-4d improved=%d\n", op, sum.Count, sum.Improved)
+		}
+		err := ioutil.WriteFile(file, []byte(g.Graph("ancestry", best.Id, 0).String()), 0644)
+		if err != nil {
+			fmt.Println("error writing ancestry graph:", err)
+		}
+	}
+}
+
 // main GP routine
 func main() {
 	// get options
 	var maxSize, maxDepth int
-	var dataFile string
+	var adapt, scale, baldwin bool
+	var optimise, patience int
+	var validFrac, testFrac float64
+	var dataFile, ancestryFile, csvFile, target, grammarFile, repr, elitesFile string
+	var ercMin, ercMax int
+	var fillMissing, mathFuncs bool
 	flag.IntVar(&maxSize, "size", 0, "maximum tree size - zero for none")
 	flag.IntVar(&maxDepth, "depth", 0, "maximum tree depth - zero for none")
+	flag.BoolVar(&scale, "scale", false, "apply linear scaling to each individual before evaluating fitness")
+	flag.IntVar(&optimise, "optimise", 0, "max evaluations to tune constants for each individual - zero for none")
+	flag.BoolVar(&baldwin, "baldwin", false, "do not write optimised constants back to the code")
+	flag.BoolVar(&adapt, "adapt", false, "use adaptive pursuit to choose between mutation operators")
+	flag.Float64Var(&validFrac, "valid", 0, "fraction of data to use for validation")
+	flag.Float64Var(&testFrac, "test", 0, "fraction of data to use for final test")
+	flag.IntVar(&patience, "patience", 0, "stop if validation fitness does not improve for this many generations")
 	flag.StringVar(&dataFile, "trainset", "poly.dat", "file with training function")
+	flag.StringVar(&csvFile, "csv", "", "read data from CSV or TSV file with header instead of trainset")
+	flag.StringVar(&target, "column", "", "name of target column in CSV file - default is last column")
+	flag.BoolVar(&fillMissing, "fill", false, "replace missing values in CSV file by column mean instead of skipping")
+	flag.BoolVar(&mathFuncs, "math", false, "add sin, cos, exp, log and sqrt functions to primitive set")
+	flag.IntVar(&ercMin, "ercmin", -5, "minimum random constant for CSV data")
+	flag.IntVar(&ercMax, "ercmax", 5, "maximum random constant for CSV data")
+	flag.StringVar(&ancestryFile, "ancestry", "", "write ancestry graph of best individual to this dot file")
+	flag.StringVar(&grammarFile, "grammar", "", "BNF grammar file to constrain the generated trees")
+	flag.StringVar(&repr, "repr", "tree", "genome representation: tree, lgp or cgp")
+	flag.StringVar(&elitesFile, "elites", "", "run MAP-Elites over size and depth and write the elites to this JSON file")
 	opts := util.DefaultOptions
 	util.ParseFlags(&opts)
+	if validFrac < 0 || testFrac < 0 || validFrac+testFrac >= 1 {
+		fmt.Println("-valid and -test must not be negative and must leave some data for training")
+		os.Exit(1)
+	}
+	if scale && grammarFile != "" {
+		fmt.Println("-scale cannot be used with -grammar: the scale node is not part of the grammar")
+		os.Exit(1)
+	}
+	if scale && repr != "tree" {
+		fmt.Println("-scale cannot be used with -repr", repr, "- the scale node is only supported for trees")
+		os.Exit(1)
+	}
 
 	// create primitive set
-	ercMin, ercMax, trainSet := getData(dataFile)
-	pset := gp.CreatePrimSet(1, "x")
+	var data *num.Dataset
+	if csvFile != "" {
+		missing := num.DropMissing
+		if fillMissing {
+			missing = num.MeanMissing
+		}
+		var err error
+		if data, err = num.ReadFile(csvFile, target, missing); err != nil {
+			fmt.Println("error reading", csvFile, err)
+			os.Exit(1)
+		}
+		dataFile = csvFile
+		fmt.Println("read", data)
+	} else {
+		ercMin, ercMax, data = getData(dataFile)
+	}
+	trainSet, validSet, testSet := data, num.NewDataset(), num.NewDataset()
+	if validFrac > 0 || testFrac > 0 {
+		parts := data.Shuffle().Split(1-validFrac-testFrac, validFrac)
+		trainSet, validSet, testSet = parts[0], parts[1], parts[2]
+		fmt.Println("split data: train =", trainSet.Len(), "validation =", validSet.Len(), "test =", testSet.Len())
+	}
+	pset := data.PrimSet()
 	pset.Add(num.Add, num.Sub, num.Mul, num.Div)
+	if mathFuncs {
+		pset.Add(num.Sin, num.Cos, num.Exp, num.Log, num.Sqrt)
+	}
 	pset.Add(num.Ephemeral("ERC", ercGen(ercMin, ercMax)))
 
 	// setup model
@@ -96,7 +195,7 @@ func main() {
 		PrimitiveSet:  pset,
 		Generator:     gp.GenRamped(pset, 1, 3),
 		PopSize:       opts.PopSize,
-		Fitness:       fitnessFunc(trainSet),
+		Fitness:       trainSet.Fitness,
 		Offspring:     gp.Tournament(opts.TournSize),
 		Mutate:        gp.MutUniform(gp.GenGrow(pset, 0, 2)),
 		MutateProb:    opts.MutateProb,
@@ -104,6 +203,42 @@ func main() {
 		CrossoverProb: opts.CrossoverProb,
 		Threads:       opts.Threads,
 	}
+	if scale {
+		problem.Hooks = []gp.Hook{trainSet.LinearScaling()}
+	}
+	if optimise > 0 {
+		problem.Hooks = append(problem.Hooks, num.OptimiseConstants(optimise, !baldwin))
+	}
+	// mutation operators to choose between with -adapt
+	mutations := []gp.Variation{
+		gp.MutUniform(gp.GenGrow(pset, 0, 2)),
+		gp.MutUniform(gp.GenFull(pset, 0, 1)),
+		gp.MutUniform(gp.GenRamped(pset, 1, 4)),
+	}
+	switch repr {
+	case "lgp":
+		m := lgp.NewMachine(pset, 4, 5, 50)
+		problem.Generator = m.Gen()
+		problem.Mutate = m.MutMicro()
+		problem.Crossover = m.Crossover()
+		mutations = []gp.Variation{m.MutMicro(), m.MutMacro()}
+	case "cgp":
+		g := cgp.NewGrid(pset, 50, 0)
+		problem.Generator = g.Gen()
+		problem.Mutate = g.MutActive()
+		problem.Crossover = g.Crossover()
+		mutations = []gp.Variation{g.MutActive(), g.MutPoint(0.05)}
+	}
+	if grammarFile != "" {
+		g := readGrammar(grammarFile, pset)
+		problem.Generator = g.GenRamped(1, 3)
+		problem.Mutate = g.Mutate(0, 2)
+		problem.Crossover = g.Crossover()
+		mutations = []gp.Variation{g.Mutate(0, 2), g.Mutate(1, 4)}
+	}
+	if adapt {
+		problem.Mutate = gp.AdaptivePursuit(0.1, 0.3, 0.3, mutations...)
+	}
 	if maxDepth > 0 {
 		problem.AddDecorator(gp.DepthLimit(maxDepth))
 	}
@@ -114,14 +249,52 @@ func main() {
 
 	// run
 	logger := stats.NewLogger(opts.MaxGen, opts.TargetFitness)
+	logger.Name = dataFile
+	logger.Outputs = trainSet.Outputs
+	if validSet.Len() > 0 {
+		logger.Validate = validSet.Validate
+		logger.Patience = patience
+		stats.LogColumn = append(stats.LogColumn, "Valid")
+	}
+	if ancestryFile != "" {
+		logger.Genealogy = gp.NewGenealogy()
+		logger.OnDone = writeAncestry(logger.Genealogy, ancestryFile)
+	}
+	if elitesFile != "" {
+		logger.Elites = gp.NewElites(gp.SizeDim(1, 61, 20), gp.DepthDim(0, 12, 12))
+		stats.LogColumn = append(stats.LogColumn, "Coverage")
+		onDone := logger.OnDone
+		logger.OnDone = func(best *gp.Individual) {
+			if onDone != nil {
+				onDone(best)
+			}
+			writeElites(logger.Elites, elitesFile)
+		}
+	}
 	if opts.Plot {
 		gp.GraphDPI = "60"
-		logger.RegisterPlot("graph", plotTarget(trainSet), plotBest(trainSet))
-		stats.MainLoop(problem, logger, ":8080", "../web")
+		logger.RegisterPlot("graph", plotTarget(data), plotBest(data))
+		if logger.Elites != nil {
+			logger.RegisterSVGPlot("elites", stats.ElitesPlot(logger.Elites, 500, 400))
+		}
+		stats.Headless = opts.Headless
+		logger.Interactive = opts.Step
+		stats.MainLoop(problem, logger, opts.Port, "../web")
 	} else {
 		fmt.Println()
 		logger.PrintStats = true
 		logger.PrintBest = opts.Verbose
-		problem.Run(logger)
+		var best *gp.Individual
+		if logger.Elites != nil {
+			best = problem.RunElites(logger.Elites, logger).Best()
+		} else {
+			best = problem.Run(logger).Best()
+		}
+		if logger.Validate != nil {
+			best = logger.BestValid()
+		}
+		if testSet.Len() > 0 {
+			fmt.Printf("test set: fitness = %.3g  RMSE = %.3g\n", testSet.Validate(best.Code), testSet.RMSE(best.Code))
+		}
 	}
 }
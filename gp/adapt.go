@@ -0,0 +1,232 @@
+package gp
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// An Adapter is a Variation which updates its parameters at the end of each generation.
+// Adapt is called by Model.Run with the newly evaluated population, and Probs returns
+// the current probability of selecting each operation, in the order they were given.
+type Adapter interface {
+	Variation
+	Adapt(pop Population)
+	Probs() []float64
+}
+
+// adaptive variation chooses one of ops at random each time it is called
+type adaptive struct {
+	ops             []Variation
+	prob, qual      []float64
+	applied, better []int
+	rateQual        []float64
+	minProb         float64
+	alpha, beta     float64
+	name, method    string
+}
+
+// credit records the op in an adaptive variation which helped to create an individual
+type credit struct {
+	a  *adaptive
+	op int
+}
+
+// AdaptivePursuit returns a Variation which picks one of ops each time it is applied.
+// The quality of each operation is estimated from the fraction of its children which are fitter
+// than their parents, with learning rate alpha. The probability of the best operation is moved
+// towards 1-(n-1)*minProb, and the others towards minProb, with rate beta.
+// When used in a Model the CrossoverProb and MutateProb are adapted in the same way.
+func AdaptivePursuit(minProb, alpha, beta float64, ops ...Variation) Adapter {
+	return newAdaptive("AdaptivePursuit", minProb, alpha, beta, ops)
+}
+
+// ProbMatching returns a Variation which picks one of ops each time it is applied.
+// Each operation is chosen with probability proportional to its estimated quality,
+// with a floor of minProb. Alpha is the learning rate for the quality estimate.
+// When used in a Model the CrossoverProb and MutateProb are adapted in the same way.
+func ProbMatching(minProb, alpha float64, ops ...Variation) Adapter {
+	return newAdaptive("ProbMatching", minProb, alpha, 0, ops)
+}
+
+func newAdaptive(method string, minProb, alpha, beta float64, ops []Variation) *adaptive {
+	if len(ops) == 0 || minProb*float64(len(ops)) > 1 || minProb > 0.5 {
+		panic("adaptive variation: invalid minimum probability or no operations")
+	}
+	a := &adaptive{
+		ops:      ops,
+		prob:     make([]float64, len(ops)),
+		qual:     make([]float64, len(ops)),
+		applied:  make([]int, len(ops)),
+		better:   make([]int, len(ops)),
+		rateQual: []float64{1, 1},
+		minProb:  minProb,
+		alpha:    alpha,
+		beta:     beta,
+		method:   method,
+	}
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		a.prob[i] = 1 / float64(len(ops))
+		a.qual[i] = 1
+		names[i] = op.String()
+	}
+	a.name = fmt.Sprintf("%s(%s)", method, strings.Join(names, ","))
+	return a
+}
+
+func (a *adaptive) String() string {
+	return a.name
+}
+
+func (a *adaptive) AddDecorator(decor Decorator) {
+	for _, op := range a.ops {
+		op.AddDecorator(decor)
+	}
+	a.name += fmt.Sprintf("<%s>", decor)
+}
+
+// apply the chosen op and tag each changed child so it can be credited in Adapt
+func (a *adaptive) Variate(in Population) Population {
+	r := rand.Float64()
+	for i, p := range a.prob {
+		if r -= p; r < 0 || i == len(a.prob)-1 {
+			out := a.ops[i].Variate(in)
+			a.applied[i] += len(in)
+			for j, ind := range out {
+				if j >= len(in) || ind.Id != in[j].Id {
+					ind.credit = append(ind.credit, credit{a, i})
+				}
+			}
+			return out
+		}
+	}
+	return in
+}
+
+func (a *adaptive) Probs() []float64 {
+	return append([]float64{}, a.prob...)
+}
+
+// update the quality estimate for each op which was applied in this generation, then the probabilities
+func (a *adaptive) Adapt(pop Population) {
+	for _, ind := range pop {
+		if !ind.fresh || !ind.FitnessValid || ind.Fitness <= ind.parentFit {
+			continue
+		}
+		for _, c := range ind.credit {
+			if c.a == a {
+				a.better[c.op]++
+			}
+		}
+	}
+	for i := range a.ops {
+		if a.applied[i] > 0 {
+			reward := math.Min(1, float64(a.better[i])/float64(a.applied[i]))
+			a.qual[i] += a.alpha * (reward - a.qual[i])
+		}
+		a.applied[i], a.better[i] = 0, 0
+	}
+	a.update(a.prob, a.qual)
+}
+
+// update the crossover and mutation probabilities from the fraction of children from each which
+// were fitter than their parents. The sum of the two is kept the same, with each limited to 1.
+func (a *adaptive) adaptRates(pop Population, cross, mutate Variation, cxProb, mutProb float64) (float64, float64) {
+	total := cxProb + mutProb
+	if total <= 0 {
+		return cxProb, mutProb
+	}
+	stats := pop.OpStats()
+	for i, v := range []Variation{cross, mutate} {
+		var applied, better int
+		for _, name := range opNames(v) {
+			if s, ok := stats[name]; ok {
+				applied += s.Applied
+				better += s.Better
+			}
+		}
+		if applied > 0 {
+			a.rateQual[i] += a.alpha * (float64(better)/float64(applied) - a.rateQual[i])
+		}
+	}
+	prob := []float64{cxProb / total, mutProb / total}
+	a.update(prob, a.rateQual)
+	return math.Min(1, prob[0]*total), math.Min(1, prob[1]*total)
+}
+
+// set the probabilities using adaptive pursuit or probability matching
+func (a *adaptive) update(prob, qual []float64) {
+	n := float64(len(prob))
+	if a.method == "AdaptivePursuit" {
+		best := 0
+		for i, q := range qual {
+			if q > qual[best] {
+				best = i
+			}
+		}
+		maxProb := 1 - (n-1)*a.minProb
+		for i := range prob {
+			if i == best {
+				prob[i] += a.beta * (maxProb - prob[i])
+			} else {
+				prob[i] += a.beta * (a.minProb - prob[i])
+			}
+		}
+	} else {
+		total := 0.0
+		for _, q := range qual {
+			total += q
+		}
+		for i, q := range qual {
+			if total > 0 {
+				prob[i] = a.minProb + (1-n*a.minProb)*q/total
+			} else {
+				prob[i] = 1 / n
+			}
+		}
+	}
+}
+
+// adapt the Mutate and Crossover variations which implement Adapter, and the crossover and
+// mutation probabilities using the first adaptive variation found.
+func (m *Model) adapt(pop Population) {
+	var rates *adaptive
+	for _, v := range []Variation{m.Crossover, m.Mutate} {
+		if a, ok := v.(Adapter); ok {
+			a.Adapt(pop)
+			if ad, ok := a.(*adaptive); ok && rates == nil {
+				rates = ad
+			}
+		}
+	}
+	if rates != nil {
+		m.CrossoverProb, m.MutateProb = rates.adaptRates(pop, m.Crossover, m.Mutate, m.CrossoverProb, m.MutateProb)
+	}
+}
+
+// distinct names recorded for the ops in v
+func opNames(v Variation) []string {
+	a, ok := v.(*adaptive)
+	if !ok {
+		return []string{variationName(v)}
+	}
+	names := []string{}
+	seen := map[string]bool{}
+	for _, op := range a.ops {
+		if name := variationName(op); !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	return names
+}
+
+// name used to record the op which created an individual, excluding any decorators
+func variationName(v Variation) string {
+	if vv, ok := v.(*variation); ok {
+		return vv.op
+	}
+	return v.String()
+}
@@ -0,0 +1,262 @@
+package gp
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// A Pairing decides which games are played between a list of players and their opponents for
+// competitive coevolution. The contest function returns the scores for each of the two players,
+// which should be in the range 0 to 1. Play returns the fitness for each player.
+type Pairing interface {
+	Play(players, opponents []Expr, contest func(a, b Expr) (float64, float64)) []float64
+	String() string
+}
+
+type allVsAll struct{}
+
+// AllVsAll returns a pairing where each player plays every opponent and the fitness is the
+// mean score.
+func AllVsAll() Pairing {
+	return allVsAll{}
+}
+
+func (p allVsAll) String() string { return "AllVsAll" }
+
+func (p allVsAll) Play(players, opponents []Expr, contest func(a, b Expr) (float64, float64)) []float64 {
+	fitness := make([]float64, len(players))
+	for i, player := range players {
+		for _, opponent := range opponents {
+			score, _ := contest(player, opponent)
+			fitness[i] += score / float64(len(opponents))
+		}
+	}
+	return fitness
+}
+
+type randomK struct{ k int }
+
+// RandomK returns a pairing where each player plays k opponents chosen at random and the
+// fitness is the mean score.
+func RandomK(k int) Pairing {
+	return randomK{k}
+}
+
+func (p randomK) String() string { return fmt.Sprintf("RandomK(%d)", p.k) }
+
+func (p randomK) Play(players, opponents []Expr, contest func(a, b Expr) (float64, float64)) []float64 {
+	fitness := make([]float64, len(players))
+	for i, player := range players {
+		for j := 0; j < p.k; j++ {
+			score, _ := contest(player, opponents[rand.Intn(len(opponents))])
+			fitness[i] += score / float64(p.k)
+		}
+	}
+	return fitness
+}
+
+type knockout struct{}
+
+// KnockoutTournament returns a pairing where the players compete in a single elimination
+// tournament. Players are paired at random and the one with the higher score in each game goes
+// through to the next round, with a bye if there are an odd number. The fitness is the fraction
+// of rounds won. Opponents from other populations are not used.
+func KnockoutTournament() Pairing {
+	return knockout{}
+}
+
+func (p knockout) String() string { return "KnockoutTournament" }
+
+func (p knockout) Play(players, opponents []Expr, contest func(a, b Expr) (float64, float64)) []float64 {
+	fitness := make([]float64, len(players))
+	alive := rand.Perm(len(players))
+	rounds := 0
+	for ; len(alive) > 1; rounds++ {
+		next := []int{}
+		for i := 0; i < len(alive); i += 2 {
+			winner := alive[i]
+			if i+1 < len(alive) {
+				scoreA, scoreB := contest(players[alive[i]], players[alive[i+1]])
+				if scoreB > scoreA {
+					winner = alive[i+1]
+				}
+			}
+			fitness[winner]++
+			next = append(next, winner)
+		}
+		alive = next
+	}
+	for i := range fitness {
+		if rounds > 0 {
+			fitness[i] /= float64(rounds)
+		}
+	}
+	return fitness
+}
+
+// Coevolution runs several populations together, where the fitness of each individual depends
+// on the other populations. Each species is defined by a Model which gives the generator,
+// selection and variation operators and population size. The model Fitness function is not used.
+//
+// In competitive mode each individual plays games against opponents chosen by the Pairing from
+// the other populations, or from its own population if there is only one species. The best
+// individual from each generation is added to the hall of fame for its species, which holds up
+// to FameSize individuals, and these are also used as opponents.
+//
+// In cooperative mode each individual is scored by the Team function as part of a team with
+// the best individual from the previous generation of each of the other species. If
+// Collaborators is set then it is also tried in this number of teams with random members of
+// the other species and the fitness is the best team score.
+type Coevolution struct {
+	Species       []*Model
+	Contest       func(a, b Expr) (float64, float64)
+	Pairing       Pairing
+	FameSize      int
+	Team          func(team []Expr) float64
+	Collaborators int
+	hof           []Population
+	best          []*Individual
+}
+
+// Competitive returns a new competitive coevolution setup.
+func Competitive(contest func(a, b Expr) (float64, float64), pairing Pairing, fameSize int, species ...*Model) *Coevolution {
+	return &Coevolution{Species: species, Contest: contest, Pairing: pairing, FameSize: fameSize}
+}
+
+// Cooperative returns a new cooperative coevolution setup.
+func Cooperative(team func([]Expr) float64, collaborators int, species ...*Model) *Coevolution {
+	return &Coevolution{Species: species, Team: team, Collaborators: collaborators}
+}
+
+// HallOfFame returns the current hall of fame for species n.
+func (c *Coevolution) HallOfFame(n int) Population {
+	return c.hof[n]
+}
+
+// Best returns the best individual from the last generation of each species. In cooperative mode
+// each was scored in a separate team, so together they are not necessarily the best team.
+func (c *Coevolution) Best() []*Individual {
+	return c.best
+}
+
+// Run creates a new population for each species and evolves them together. The Log method is
+// called on the corresponding logger for each species after every generation, with the number of
+// contests or teams scored for that species, and the run terminates when any of them returns true.
+// There must be one logger for each species. Returns the final populations.
+func (c *Coevolution) Run(loggers ...Logger) []Population {
+	if len(loggers) != len(c.Species) {
+		panic(fmt.Sprintf("coevolution: %d loggers for %d species", len(loggers), len(c.Species)))
+	}
+	pops := make([]Population, len(c.Species))
+	for i, m := range c.Species {
+		pops[i] = CreatePopulation(m.PopSize, m.Generator)
+	}
+	c.hof = make([]Population, len(c.Species))
+	c.best = make([]*Individual, len(c.Species))
+	for gen := 0; ; gen++ {
+		if gen > 0 {
+			for i, m := range c.Species {
+				offspring := m.Offspring.Select(pops[i], m.PopSize)
+				pops[i] = VarAnd(offspring, m.Crossover, m.Mutate, m.CrossoverProb, m.MutateProb)
+			}
+		}
+		fitness, evals := c.evaluate(pops)
+		done := false
+		for i, m := range c.Species {
+			for _, ind := range pops[i] {
+				ind.FitnessValid = false
+			}
+			pops[i], _ = pops[i].Evaluate(m, m.Threads, fitness)
+			m.adapt(pops[i])
+			c.best[i] = pops[i].Best()
+			if c.FameSize > 0 {
+				c.hof[i] = append(c.hof[i], c.best[i].Clone())
+				if len(c.hof[i]) > c.FameSize {
+					c.hof[i] = c.hof[i][1:]
+				}
+			}
+			if loggers[i].Log(pops[i], gen, evals[i]) {
+				done = true
+			}
+		}
+		if done {
+			return pops
+		}
+	}
+}
+
+// scores is a Hook which sets the fitness calculated for each individual
+type scores map[*Individual]float64
+
+func (s scores) Apply(ind *Individual, eval Evaluator) {
+	ind.Fitness, ind.FitnessValid = s[ind], true
+}
+
+func (s scores) String() string { return "Scores" }
+
+// calculate the fitness for each individual in each population and count the number of
+// contests or teams scored for each species
+func (c *Coevolution) evaluate(pops []Population) (scores, []int) {
+	fitness := scores{}
+	evals := make([]int, len(pops))
+	for i, pop := range pops {
+		var values []float64
+		if c.Team != nil {
+			values, evals[i] = c.teamFitness(pops, i)
+		} else {
+			players := make([]Expr, len(pop))
+			for j, ind := range pop {
+				players[j] = ind.Code
+			}
+			contest := func(a, b Expr) (float64, float64) {
+				evals[i]++
+				return c.Contest(a, b)
+			}
+			values = c.Pairing.Play(players, c.opponents(pops, i), contest)
+		}
+		for j, ind := range pop {
+			fitness[ind] = values[j]
+		}
+	}
+	return fitness, evals
+}
+
+// opponents for species n are the members and hall of fame of the other species
+func (c *Coevolution) opponents(pops []Population, n int) []Expr {
+	list := []Expr{}
+	for i, pop := range pops {
+		if i != n || len(pops) == 1 {
+			for _, ind := range pop {
+				list = append(list, ind.Code)
+			}
+			for _, ind := range c.hof[i] {
+				list = append(list, ind.Code)
+			}
+		}
+	}
+	return list
+}
+
+// score each member of species n as part of a team, returns the fitness and number of teams
+func (c *Coevolution) teamFitness(pops []Population, n int) ([]float64, int) {
+	fitness := make([]float64, len(pops[n]))
+	teams := 0
+	team := make([]Expr, len(pops))
+	for j, ind := range pops[n] {
+		for try := 0; try <= c.Collaborators; try++ {
+			for i, pop := range pops {
+				if try == 0 && c.best[i] != nil {
+					team[i] = c.best[i].Code
+				} else {
+					team[i] = pop[rand.Intn(len(pop))].Code
+				}
+			}
+			team[n] = ind.Code
+			if score := c.Team(team); try == 0 || score > fitness[j] {
+				fitness[j] = score
+			}
+			teams++
+		}
+	}
+	return fitness, teams
+}
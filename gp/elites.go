@@ -0,0 +1,229 @@
+package gp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A Dimension is a feature used to place individuals in a MAP-Elites archive. The range from
+// Min to Max is divided into Bins equal cells and values outside the range are put in the first
+// or last cell. Feature returns the value for an evaluated individual.
+type Dimension struct {
+	Name     string
+	Min, Max float64
+	Bins     int
+	Feature  func(ind *Individual) float64
+}
+
+// SizeDim returns a dimension with the size of each individual.
+func SizeDim(min, max float64, bins int) Dimension {
+	return Dimension{"Size", min, max, bins, func(ind *Individual) float64 { return float64(ind.Size()) }}
+}
+
+// DepthDim returns a dimension with the depth of each individual.
+func DepthDim(min, max float64, bins int) Dimension {
+	return Dimension{"Depth", min, max, bins, func(ind *Individual) float64 { return float64(ind.Depth()) }}
+}
+
+// BehaviourDim returns a dimension with element n of the behaviour descriptor returned by the
+// Model Behaviour function.
+func BehaviourDim(name string, n int, min, max float64, bins int) Dimension {
+	return Dimension{name, min, max, bins, func(ind *Individual) float64 {
+		if n < len(ind.Behaviour) {
+			return ind.Behaviour[n]
+		}
+		return min
+	}}
+}
+
+// Bin returns the cell number for the value.
+func (d Dimension) Bin(value float64) int {
+	bin := int((value - d.Min) / (d.Max - d.Min) * float64(d.Bins))
+	if bin < 0 {
+		return 0
+	}
+	if bin >= d.Bins {
+		return d.Bins - 1
+	}
+	return bin
+}
+
+// Elites is a MAP-Elites archive which keeps the individual with the best fitness for each cell
+// of a grid defined by a list of dimensions.
+type Elites struct {
+	Dims     []Dimension
+	cells    []*Individual
+	added    int
+	improved int
+}
+
+// NewElites creates a new empty archive with the given dimensions.
+func NewElites(dims ...Dimension) *Elites {
+	cells := 1
+	for _, d := range dims {
+		cells *= d.Bins
+	}
+	return &Elites{Dims: dims, cells: make([]*Individual, cells)}
+}
+
+// String returns the names and number of bins for each dimension.
+func (e *Elites) String() string {
+	text := "Elites("
+	for i, d := range e.Dims {
+		if i > 0 {
+			text += ","
+		}
+		text += fmt.Sprintf("%s:%d", d.Name, d.Bins)
+	}
+	return text + ")"
+}
+
+// Reset removes all of the elites from the archive.
+func (e *Elites) Reset() {
+	e.cells = make([]*Individual, len(e.cells))
+	e.added, e.improved = 0, 0
+}
+
+// Cell returns the bin for each of the dimensions for an individual.
+func (e *Elites) Cell(ind *Individual) []int {
+	bins := make([]int, len(e.Dims))
+	for i, d := range e.Dims {
+		bins[i] = d.Bin(d.Feature(ind))
+	}
+	return bins
+}
+
+// convert list of bins to index in cells slice, with the first dimension varying fastest
+func (e *Elites) index(bins []int) int {
+	index, stride := 0, 1
+	for i, d := range e.Dims {
+		index += bins[i] * stride
+		stride *= d.Bins
+	}
+	return index
+}
+
+// Get returns the elite for the cell with the given bins, or nil if it is empty.
+func (e *Elites) Get(bins ...int) *Individual {
+	return e.cells[e.index(bins)]
+}
+
+// Cells returns the total number of cells in the grid.
+func (e *Elites) Cells() int {
+	return len(e.cells)
+}
+
+// Len returns the number of cells which have an elite.
+func (e *Elites) Len() int {
+	count := 0
+	for _, ind := range e.cells {
+		if ind != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Coverage returns the fraction of cells which have an elite.
+func (e *Elites) Coverage() float64 {
+	return float64(e.Len()) / float64(len(e.cells))
+}
+
+// Add places each evaluated individual in its cell if the cell is empty or if it has a better
+// fitness than the current elite. Returns the number of cells which were filled or improved.
+func (e *Elites) Add(pop Population) int {
+	e.added, e.improved = 0, 0
+	for _, ind := range pop {
+		if !ind.FitnessValid {
+			continue
+		}
+		i := e.index(e.Cell(ind))
+		if e.cells[i] == nil {
+			e.added++
+		} else if ind.Fitness > e.cells[i].Fitness {
+			e.improved++
+		} else {
+			continue
+		}
+		e.cells[i] = ind
+	}
+	return e.added + e.improved
+}
+
+// Added returns the number of empty cells filled and elites improved by the last call to Add.
+func (e *Elites) Added() (added, improved int) {
+	return e.added, e.improved
+}
+
+// Population returns the elites ordered by cell.
+func (e *Elites) Population() Population {
+	pop := Population{}
+	for _, ind := range e.cells {
+		if ind != nil {
+			pop = append(pop, ind)
+		}
+	}
+	return pop
+}
+
+// An EliteResult holds the details for one cell in the archive.
+type EliteResult struct {
+	Cell     []int
+	Features []float64
+	Fitness  float64
+	Size     int
+	Code     string
+}
+
+// Results returns the details of each elite ordered by cell.
+func (e *Elites) Results() []EliteResult {
+	results := []EliteResult{}
+	for _, ind := range e.Population() {
+		r := EliteResult{Cell: e.Cell(ind), Fitness: ind.Fitness, Size: ind.Size(), Code: ind.Code.Format()}
+		for _, d := range e.Dims {
+			r.Features = append(r.Features, d.Feature(ind))
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// WriteJSON writes the dimensions and the results for each elite to w in JSON format.
+func (e *Elites) WriteJSON(w io.Writer) error {
+	type dim struct {
+		Name     string
+		Min, Max float64
+		Bins     int
+	}
+	data := struct {
+		Dims     []dim
+		Coverage float64
+		Elites   []EliteResult
+	}{Coverage: e.Coverage(), Elites: e.Results()}
+	for _, d := range e.Dims {
+		data.Dims = append(data.Dims, dim{d.Name, d.Min, d.Max, d.Bins})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// RunElites is an alternative to Run which uses the MAP-Elites algorithm. An initial population
+// is generated and added to the archive. Then at each generation PopSize parents are chosen at
+// random from the elites and new candidates created using crossover and mutation with the model
+// probabilities are evaluated and added to the archive. The Log method is called with the
+// current elites after each generation, and the final elites are returned.
+func (m *Model) RunElites(e *Elites, l Logger) Population {
+	pop, evals := CreatePopulation(m.PopSize, m.Generator).Evaluate(m, m.Threads, m.Hooks...)
+	e.Add(pop)
+	for gen := 0; !l.Log(e.Population(), gen, evals); {
+		gen++
+		parents := RandomSel().Select(e.Population(), m.PopSize)
+		pop = VarAnd(parents, m.Crossover, m.Mutate, m.CrossoverProb, m.MutateProb)
+		pop, evals = pop.Evaluate(m, m.Threads, m.Hooks...)
+		m.adapt(pop)
+		e.Add(pop)
+	}
+	return e.Population()
+}
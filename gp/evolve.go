@@ -26,7 +26,10 @@ type Decorator interface {
 	String() string
 }
 
-// The Model type encapsulates a complete problem
+// The Model type encapsulates a complete problem.
+// Hooks are applied to each new individual before its fitness is evaluated.
+// If Behaviour is set it is called instead of Fitness to return both the fitness and a
+// behaviour descriptor for each individual, for use with NoveltySelect.
 type Model struct {
 	PrimitiveSet              *PrimSet
 	PopSize, Threads          int
@@ -34,6 +37,8 @@ type Model struct {
 	Offspring                 Selector
 	MutateProb, CrossoverProb float64
 	Mutate, Crossover         Variation
+	Hooks                     []Hook
+	Behaviour                 func(Expr) (float64, bool, []float64)
 	Fitness                   func(Expr) (float64, bool)
 }
 
@@ -57,24 +62,53 @@ func (m *Model) AddDecorator(decor Decorator) {
 // using the VarAnd algorithm. The Log method is called on the Logger for each generation.
 // If it returns true then the run terminates.
 func (m *Model) Run(l Logger) Population {
-	gen, evals := 0, 0
-	pop := CreatePopulation(m.PopSize, m.Generator)
-	pop, evals = pop.Evaluate(m, m.Threads)
+	return m.RunFrom(CreatePopulation(m.PopSize, m.Generator), 0, l)
+}
+
+// RunFrom is like Run but starts from an existing population at generation gen,
+// e.g. to resume from a population saved with Population.Write.
+// If the Mutate or Crossover variations implement the Adapter interface then they, and the
+// crossover and mutation probabilities, are updated after each generation is evaluated,
+// as is the archive if using NoveltySelect.
+func (m *Model) RunFrom(pop Population, gen int, l Logger) Population {
+	pop, evals := pop.Evaluate(m, m.Threads, m.Hooks...)
+	m.updateNovelty(pop)
 	for !l.Log(pop, gen, evals) {
 		gen++
 		offspring := m.Offspring.Select(pop, m.PopSize)
 		pop = VarAnd(offspring, m.Crossover, m.Mutate, m.CrossoverProb, m.MutateProb)
-		pop, evals = pop.Evaluate(m, m.Threads)
+		pop, evals = pop.Evaluate(m, m.Threads, m.Hooks...)
+		m.updateNovelty(pop)
+		m.adapt(pop)
 	}
 	return pop
 }
 
+// Params returns the config parameters for this run formatted as name = value, one per line.
+// Function valued fields such as Fitness and Behaviour, and any empty lists, are skipped.
+func (m *Model) Params() []string {
+	s := reflect.ValueOf(m).Elem()
+	lines := []string{}
+	for i := 0; i < s.NumField(); i++ {
+		name, field := s.Type().Field(i).Name, s.Field(i)
+		if field.Kind() == reflect.Func || (field.Kind() == reflect.Slice && field.Len() == 0) {
+			continue
+		}
+		lines = append(lines, FormatParam(name, field.Interface()))
+	}
+	return lines
+}
+
+// FormatParam returns a config parameter name and value in the format used by PrintParams.
+func FormatParam(name string, value interface{}) string {
+	return fmt.Sprintf("%14s = %v", name, value)
+}
+
 // PrintParams prints the config parameters for this run to stdout
 func (m *Model) PrintParams(title ...interface{}) {
 	fmt.Println(title...)
-	s := reflect.ValueOf(m).Elem()
-	for i := 0; i < s.NumField()-1; i++ {
-		fmt.Printf("%14s = %v\n", s.Type().Field(i).Name, s.Field(i).Interface())
+	for _, line := range m.Params() {
+		fmt.Println(line)
 	}
 }
 
@@ -109,7 +143,7 @@ func (pop Population) Clone() Population {
 type variation struct {
 	decorators []Decorator
 	vfunc      func(in Population) (out Population)
-	name       string
+	name, op   string
 }
 
 func (v *variation) String() string {
@@ -123,14 +157,57 @@ func (v *variation) AddDecorator(decor Decorator) {
 
 func (v *variation) Variate(in Population) Population {
 	out := v.vfunc(in.Clone())
+	setParents(in, out, v.op)
 	for _, decor := range v.decorators {
 		for i := range in {
-			out[i] = decor.Decorate(in[i], out[i])
+			if out[i].Id != in[i].Id {
+				if out[i] = decor.Decorate(in[i], out[i]); out[i] == in[i] {
+					in[i].rejects = append(in[i].rejects, v.op, decor.String())
+				}
+			}
 		}
 	}
 	return out
 }
 
+// set the parent ids and op name for each new individual in out. Where the input has not yet
+// been evaluated, e.g. mutation following crossover, the child inherits its parents.
+func setParents(in, out Population, name string) {
+	parents := make([]uint64, 0, len(in))
+	op := name
+	parentFit := 0.0
+	var credits []credit
+	for i, ind := range in {
+		if !ind.evaluated && ind.Parents != nil {
+			parents = append(parents, ind.Parents...)
+			credits = append(credits, ind.credit...)
+			op = ind.Op + "+" + name
+			parentFit = ind.parentFit
+		} else {
+			parents = append(parents, ind.Id)
+			if i == 0 || ind.Fitness > parentFit {
+				parentFit = ind.Fitness
+			}
+		}
+	}
+	for i, ind := range out {
+		if i >= len(in) || ind.Id != in[i].Id {
+			ind.Parents, ind.Op, ind.parentFit = parents, op, parentFit
+			ind.credit = append([]credit(nil), credits...)
+			if i < len(in) {
+				ind.rejects = in[i].rejects
+			}
+		}
+	}
+}
+
+// NewVariation returns a Variation which applies vfunc to a copy of the input individuals.
+// It can be used to implement custom genetic operators: vfunc should return a new Individual
+// created with Create for each child which is changed. Any decorators are applied to the output.
+func NewVariation(name string, vfunc func(in Population) Population) Variation {
+	return &variation{[]Decorator{}, vfunc, name, name}
+}
+
 // MutUniform returns a mutation variation which operates on an Individual.
 // A random point in the code tree is selected and is replaced by a tree generated by the
 // provided Generator from the pset primitive set.
@@ -142,7 +219,8 @@ func MutUniform(gen Generator) Variation {
 		ind[0] = Create(tree.ReplaceSubtree(pos, newtree))
 		return ind
 	}
-	return &variation{[]Decorator{}, mutate, fmt.Sprintf("MutUniform(%s)", gen)}
+	name := fmt.Sprintf("MutUniform(%s)", gen)
+	return &variation{[]Decorator{}, mutate, name, name}
 }
 
 // CxOnePoint returns a crossover Variation which operates on a pair of Individuals.
@@ -158,7 +236,7 @@ func CxOnePoint() Variation {
 		ind[1] = Create(ind[1].Code.ReplaceSubtree(pos2, subtree1))
 		return ind
 	}
-	return &variation{[]Decorator{}, cross, "CxOnePoint"}
+	return &variation{[]Decorator{}, cross, "CxOnePoint", "CxOnePoint"}
 }
 
 // Best returns the best individual by fitness.
@@ -1,7 +1,10 @@
 package gp
 
 import (
+	"fmt"
+	"hash/fnv"
 	"math/rand"
+	"strconv"
 	"strings"
 )
 
@@ -91,6 +94,25 @@ func Variable(name string, narg int) Opcode {
 	return variable{&BaseFunc{name, 0}, narg}
 }
 
+// Formatted returns a copy of the opcode where the Format method uses the given format string,
+// which should have a %s verb for each of the arguments, e.g. "(%s <= %s ? %s : %s)".
+func Formatted(op Opcode, format string) Opcode {
+	return formatted{op, format}
+}
+
+type formatted struct {
+	Opcode
+	format string
+}
+
+func (o formatted) Format(args ...string) string {
+	iargs := make([]interface{}, len(args))
+	for i, arg := range args {
+		iargs[i] = arg
+	}
+	return fmt.Sprintf(o.format, iargs...)
+}
+
 // Clone makes a copy of an expression.
 func (e Expr) Clone() Expr {
 	return append([]Opcode{}, e...)
@@ -193,3 +215,28 @@ func (e Expr) RandomSubtree() (pos int, subtree Expr) {
 	subtree = e[pos : end+1].Clone()
 	return
 }
+
+// Hashes returns a hash code for the subtree starting at each position in the expression.
+// Subtrees with the same opcodes in the same order will have the same hash.
+func (e Expr) Hashes() []uint64 {
+	hashes := make([]uint64, len(e))
+	stack := make([]uint64, 0, len(e))
+	for i := len(e) - 1; i >= 0; i-- {
+		h := fnv.New64a()
+		h.Write([]byte(e[i].String() + "/" + strconv.Itoa(e[i].Arity())))
+		val := h.Sum64()
+		for j := 0; j < e[i].Arity(); j++ {
+			end := len(stack) - 1
+			val = val*1099511628211 ^ stack[end]
+			stack = stack[:end]
+		}
+		stack = append(stack, val)
+		hashes[i] = val
+	}
+	return hashes
+}
+
+// Hash returns a hash code for the expression.
+func (e Expr) Hash() uint64 {
+	return e.Hashes()[0]
+}
@@ -0,0 +1,160 @@
+package gp
+
+import (
+	gv "code.google.com/p/gographviz"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Fill colour for nodes in ancestry graph where the child is fitter than its parents.
+var ImprovedColor = "palegreen"
+
+// A Record is the genealogy entry for an individual: Gen is the generation in which it first appeared.
+type Record struct {
+	Id      uint64
+	Parents []uint64
+	Op      string
+	Gen     int
+	Fitness float64
+	Code    Expr
+}
+
+// OpSummary has the number of times an operation appears in an ancestry and how many times the
+// child was fitter than all of its parents.
+type OpSummary struct {
+	Count, Improved int
+}
+
+// Genealogy is an optional store which records the lineage of each individual.
+type Genealogy struct {
+	sync.Mutex
+	records map[uint64]*Record
+}
+
+// NewGenealogy creates a new empty genealogy store.
+func NewGenealogy() *Genealogy {
+	return &Genealogy{records: map[uint64]*Record{}}
+}
+
+// Add records each individual from the population which has not been seen before.
+func (g *Genealogy) Add(pop Population, gen int) {
+	g.Lock()
+	defer g.Unlock()
+	for _, ind := range pop {
+		if _, ok := g.records[ind.Id]; !ok {
+			g.records[ind.Id] = &Record{
+				Id:      ind.Id,
+				Parents: ind.Parents,
+				Op:      ind.Op,
+				Gen:     gen,
+				Fitness: ind.Fitness,
+				Code:    ind.Code,
+			}
+		}
+	}
+}
+
+// Len returns the number of records in the store.
+func (g *Genealogy) Len() int {
+	g.Lock()
+	defer g.Unlock()
+	return len(g.records)
+}
+
+// Get returns the record for the given individual id, or nil if not found.
+func (g *Genealogy) Get(id uint64) *Record {
+	g.Lock()
+	defer g.Unlock()
+	return g.records[id]
+}
+
+// Improved returns true if the individual is fitter than all of its parents.
+func (g *Genealogy) Improved(r *Record) bool {
+	if len(r.Parents) == 0 {
+		return false
+	}
+	for _, id := range r.Parents {
+		if p := g.Get(id); p == nil || p.Fitness >= r.Fitness {
+			return false
+		}
+	}
+	return true
+}
+
+// Ancestry returns the records for the individual with given id and all of its ancestors
+// going back maxGen generations, or to the start of the run if maxGen is zero.
+// The list is sorted with the most recent individuals first.
+func (g *Genealogy) Ancestry(id uint64, maxGen int) []*Record {
+	g.Lock()
+	defer g.Unlock()
+	list := []*Record{}
+	r, ok := g.records[id]
+	if !ok {
+		return list
+	}
+	minGen := 0
+	if maxGen > 0 {
+		minGen = r.Gen - maxGen
+	}
+	seen := map[uint64]bool{id: true}
+	todo := []*Record{r}
+	for len(todo) > 0 {
+		r, todo = todo[0], todo[1:]
+		list = append(list, r)
+		for _, pid := range r.Parents {
+			if p, ok := g.records[pid]; ok && !seen[pid] && p.Gen >= minGen {
+				seen[pid] = true
+				todo = append(todo, p)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id > list[j].Id })
+	return list
+}
+
+// Summary counts the operations in the ancestry of the given individual and how often
+// each one produced a child which was fitter than its parents.
+func (g *Genealogy) Summary(id uint64, maxGen int) map[string]*OpSummary {
+	sum := map[string]*OpSummary{}
+	for _, r := range g.Ancestry(id, maxGen) {
+		if sum[r.Op] == nil {
+			sum[r.Op] = &OpSummary{}
+		}
+		sum[r.Op].Count++
+		if g.Improved(r) {
+			sum[r.Op].Improved++
+		}
+	}
+	return sum
+}
+
+// Graph returns a directed graphviz graph with the ancestry of the given individual.
+// Each node is labelled with the generation, operation and fitness with an edge from each parent.
+// Use Layout to render the graph.
+func (g *Genealogy) Graph(name string, id uint64, maxGen int) *gv.Graph {
+	graph := gv.NewGraph()
+	graph.SetName(name)
+	graph.SetDir(true)
+	graph.Attrs.Add("dpi", GraphDPI)
+	list := g.Ancestry(id, maxGen)
+	nodes := map[uint64]bool{}
+	for _, r := range list {
+		attrs := NodeAttrs.Copy()
+		attrs.Add("label", fmt.Sprintf(`"gen %d\n%s\n%.3g"`, r.Gen, r.Op, r.Fitness))
+		if g.Improved(r) {
+			attrs.Add("color", ImprovedColor)
+		}
+		graph.AddNode(name, strconv.FormatUint(r.Id, 10), attrs)
+		nodes[r.Id] = true
+	}
+	for _, r := range list {
+		for _, pid := range r.Parents {
+			if nodes[pid] {
+				graph.AddEdge(strconv.FormatUint(pid, 10), "", strconv.FormatUint(r.Id, 10), "", true, nil)
+			}
+		}
+	}
+	return graph
+}
@@ -119,7 +119,7 @@ func (g genBase) Generate() *Individual {
 			}
 		}
 	}
-	return &Individual{Code: code}
+	return &Individual{Code: code, Id: newId(), Op: Init}
 }
 
 func randomOp(list []Opcode) Opcode {
@@ -3,6 +3,7 @@ package gp
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
 // An Evaluator is provided by the implementation to calculate the fitness of an individual.
@@ -12,20 +13,53 @@ type Evaluator interface {
 	GetFitness(code Expr) (fit float64, ok bool)
 }
 
+// A Hook is applied to each individual by Population.Evaluate before its fitness is calculated.
+// It may update the code, e.g. to tune constants, or set the fitness directly in which case
+// the Evaluator is not called. Apply may be called from several goroutines concurrently.
+type Hook interface {
+	Apply(ind *Individual, eval Evaluator)
+	String() string
+}
+
 // An Individual element of the population has a code expression which represents the genome
 // and a fitness value as calculated by the implementation of the Evaluator interface.
 // Methods are provided to apply generic operations to individuals via the Variator interface.
+// Each new individual has a unique Id. Parents and Op record the Ids of the individuals it was
+// derived from and the name of the operation which produced it, or Init if it was generated.
+// Individuals which are copied unchanged into the next generation keep the same Id.
+// Behaviour is the descriptor returned by a BehaviourEvaluator and Novelty is calculated from
+// this when using novelty search.
 type Individual struct {
 	Code         Expr
 	Fitness      float64
 	FitnessValid bool
+	Id           uint64
+	Parents      []uint64
+	Op           string
+	Behaviour    []float64
+	Novelty      float64
 	depth        int
+	parentFit    float64
+	rejects      []string
+	credit       []credit
+	fresh        bool
+	evaluated    bool
+}
+
+// Op name for individuals created by a Generator.
+const Init = "init"
+
+var lastId uint64
+
+// get next unique individual id
+func newId() uint64 {
+	return atomic.AddUint64(&lastId, 1)
 }
 
 // Evaluate calls the eval Evaluator to calculate the fitness for each individual.
-// Work can be split into threads parallel goroutines.
+// Work can be split into threads parallel goroutines. Any hooks are applied in order first.
 // Returns the new population and the number of individuals which were evaluated.
-func (pop Population) Evaluate(eval Evaluator, threads int) (Population, int) {
+func (pop Population) Evaluate(eval Evaluator, threads int, hooks ...Hook) (Population, int) {
 	todo := make([]int, 0, len(pop))
 	for i, ind := range pop {
 		if !ind.FitnessValid {
@@ -50,7 +84,18 @@ func (pop Population) Evaluate(eval Evaluator, threads int) (Population, int) {
 		// kick off goroutine to do the work
 		go func(indices []int) {
 			for _, i := range indices {
-				pop[i].Fitness, pop[i].FitnessValid = eval.GetFitness(pop[i].Code)
+				for _, hook := range hooks {
+					hook.Apply(pop[i], eval)
+				}
+				pop[i].depth = 0
+				if !pop[i].FitnessValid {
+					if be, ok := eval.(BehaviourEvaluator); ok {
+						pop[i].Fitness, pop[i].FitnessValid, pop[i].Behaviour = be.GetBehaviour(pop[i].Code)
+					} else {
+						pop[i].Fitness, pop[i].FitnessValid = eval.GetFitness(pop[i].Code)
+					}
+				}
+				pop[i].fresh, pop[i].evaluated = true, true
 			}
 			wg.Done()
 		}(todo[start:end])
@@ -64,7 +109,7 @@ func (pop Population) Evaluate(eval Evaluator, threads int) (Population, int) {
 
 // Create constructor produces a new individual with copy of given code tree.
 func Create(code Expr) *Individual {
-	return &Individual{Code: code.Clone()}
+	return &Individual{Code: code.Clone(), Id: newId()}
 }
 
 // Clone returns a copy of the given individual.
@@ -73,6 +118,12 @@ func (ind *Individual) Clone() *Individual {
 		Code:         ind.Code.Clone(),
 		Fitness:      ind.Fitness,
 		FitnessValid: ind.FitnessValid,
+		Id:           ind.Id,
+		Parents:      ind.Parents,
+		Op:           ind.Op,
+		Behaviour:    ind.Behaviour,
+		Novelty:      ind.Novelty,
+		evaluated:    ind.evaluated,
 	}
 }
 
@@ -87,6 +138,9 @@ func (ind Individual) String() string {
 
 // Size returns the length of the code for the individual.
 func (ind *Individual) Size() int {
+	if p, ok := ind.Code.Program(); ok {
+		return p.Size()
+	}
 	return len(ind.Code)
 }
 
@@ -95,6 +149,9 @@ func (ind *Individual) Depth() int {
 	if len(ind.Code) == 0 {
 		return 0
 	}
+	if p, ok := ind.Code.Program(); ok {
+		return p.Depth()
+	}
 	if ind.depth == 0 {
 		ind.depth = ind.Code.Depth()
 	}
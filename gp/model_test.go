@@ -2,9 +2,14 @@
 package gp_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"github.com/jnb666/gogp/gp"
 	"github.com/jnb666/gogp/num"
 	"github.com/jnb666/gogp/stats"
+	"math"
+	"strings"
+	"testing"
 )
 
 // calc least squares difference and return as normalised fitness from 0->1
@@ -52,3 +57,331 @@ func ExampleModel() {
 	// 7        294      1        0.152    0.17     11.1     35       3.48     10
 	// ** SUCCESS **
 }
+
+// Test listing the model parameters.
+func TestParams(t *testing.T) {
+	pset := gp.CreatePrimSet(1, "x")
+	behaviour := func(code gp.Expr) (float64, bool, []float64) { return 0, true, nil }
+	problem := gp.Model{PrimitiveSet: pset, PopSize: 10, Fitness: getFitness, Behaviour: behaviour}
+	params := strings.Join(problem.Params(), "\n")
+	t.Log("\n" + params)
+	if !strings.Contains(params, "PopSize = 10") || strings.Contains(params, "Hooks") ||
+		strings.Contains(params, "Fitness") || strings.Contains(params, "Behaviour") {
+		t.Error("invalid params")
+	}
+}
+
+// Test recording the genealogy of a run.
+func TestGenealogy(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(num.Add, num.Sub, num.Mul, num.Div, num.Neg, num.V(0), num.V(1))
+	problem := gp.Model{
+		PrimitiveSet:  pset,
+		Generator:     gp.GenFull(pset, 1, 3),
+		PopSize:       200,
+		Fitness:       getFitness,
+		Offspring:     gp.Tournament(3),
+		Mutate:        gp.MutUniform(gp.GenGrow(pset, 0, 2)),
+		MutateProb:    0.2,
+		Crossover:     gp.CxOnePoint(),
+		CrossoverProb: 0.5,
+		Threads:       1,
+	}
+	logger := &stats.Logger{MaxGen: 5, TargetFitness: 1, Genealogy: gp.NewGenealogy()}
+	pop := problem.Run(logger)
+	best := pop.Best()
+	list := logger.Genealogy.Ancestry(best.Id, 0)
+	if len(list) == 0 || list[0].Id != best.Id {
+		t.Fatal("ancestry should start with the best individual")
+	}
+	for _, r := range list {
+		if r.Op == gp.Init {
+			if r.Gen != 0 || len(r.Parents) != 0 {
+				t.Error("initial individual should have no parents", r)
+			}
+		} else if len(r.Parents) == 0 {
+			t.Error("missing parents", r)
+		}
+	}
+	for op, sum := range logger.Genealogy.Summary(best.Id, 0) {
+		t.Logf("%s: %+v", op, sum)
+	}
+	t.Log(logger.Genealogy.Graph("ancestry", best.Id, 2))
+	// evaluated individual with invalid fitness is still the parent
+	problem.Fitness = func(code gp.Expr) (float64, bool) { return 0, false }
+	pop, _ = pop[:1].Clone().Evaluate(&problem, 1)
+	pop[0].Parents = []uint64{0}
+	child := problem.Mutate.Variate(pop)[0]
+	if len(child.Parents) != 1 || child.Parents[0] != pop[0].Id || child.Op != problem.Mutate.String() {
+		t.Errorf("invalid parents %v op %s", child.Parents, child.Op)
+	}
+}
+
+// Test adaptive operator probabilities.
+func TestAdaptive(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(num.Add, num.Sub, num.Mul, num.Div, num.Neg, num.V(0), num.V(1))
+	grow := gp.GenGrow(pset, 0, 2)
+	for _, mutate := range []gp.Adapter{
+		gp.AdaptivePursuit(0.1, 0.5, 0.5, gp.MutUniform(grow), gp.MutUniform(gp.GenFull(pset, 4, 5)), gp.MutUniform(grow)),
+		gp.ProbMatching(0.1, 0.5, gp.MutUniform(grow), gp.MutUniform(gp.GenFull(pset, 4, 5)), gp.MutUniform(grow)),
+	} {
+		problem := gp.Model{
+			PrimitiveSet:  pset,
+			Generator:     gp.GenFull(pset, 1, 3),
+			PopSize:       200,
+			Fitness:       getFitness,
+			Offspring:     gp.Tournament(3),
+			Mutate:        mutate,
+			MutateProb:    0.5,
+			Crossover:     gp.CxOnePoint(),
+			CrossoverProb: 0.5,
+			Threads:       1,
+		}
+		problem.AddDecorator(gp.SizeLimit(30))
+		problem.Run(&stats.Logger{MaxGen: 10, TargetFitness: 1})
+		probs := mutate.Probs()
+		t.Logf("%s: probs = %.3f", mutate, probs)
+		if len(probs) != 3 {
+			t.Fatal("expecting a probability for each op, got", len(probs))
+		}
+		total := 0.0
+		for _, p := range probs {
+			if p < 0.1-1e-9 {
+				t.Error("probability is below minimum")
+			}
+			total += p
+		}
+		if total < 0.999 || total > 1.001 {
+			t.Error("probabilities should sum to 1", total)
+		}
+		t.Logf("crossover prob = %.3f mutate prob = %.3f", problem.CrossoverProb, problem.MutateProb)
+		if problem.CrossoverProb == 0.5 || math.Abs(problem.CrossoverProb+problem.MutateProb-1) > 1e-9 {
+			t.Error("crossover and mutation probabilities should be adapted with the same sum")
+		}
+	}
+}
+
+// test novelty search using the output for each test point as the behaviour
+func TestNovelty(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(num.Add, num.Sub, num.Mul, num.Div, num.Neg, num.V(0), num.V(1))
+	archive := gp.NewArchive(10, 1, 50)
+	problem := gp.Model{
+		PrimitiveSet: pset,
+		Generator:    gp.GenFull(pset, 1, 3),
+		PopSize:      100,
+		Fitness:      getFitness,
+		Behaviour: func(code gp.Expr) (float64, bool, []float64) {
+			behaviour := []float64{}
+			for x := -1.0; x <= 1.0; x += 0.5 {
+				behaviour = append(behaviour, float64(code.Eval(num.V(x)).(num.V)))
+			}
+			fit, ok := getFitness(code)
+			return fit, ok, behaviour
+		},
+		Offspring:     gp.NoveltySelect(gp.Tournament(3), archive, 1),
+		Mutate:        gp.MutUniform(gp.GenGrow(pset, 0, 2)),
+		MutateProb:    0.2,
+		Crossover:     gp.CxOnePoint(),
+		CrossoverProb: 0.5,
+		Threads:       1,
+	}
+	logger := &stats.Logger{MaxGen: 5, TargetFitness: 1, Archive: archive}
+	pop := problem.Run(logger)
+	novelty := 0.0
+	for _, ind := range pop {
+		novelty += ind.Novelty / float64(len(pop))
+	}
+	t.Logf("archive size=%d threshold=%.3g avg novelty=%.3g", archive.Len(), archive.Threshold, novelty)
+	if archive.Len() == 0 || archive.Len() > 50 || novelty <= 0 {
+		t.Error("expecting novel individuals in archive")
+	}
+	// select the most novel individual - fitness should not be changed
+	pop[0].Behaviour, pop[0].Novelty = []float64{1e6}, 0
+	fitness := pop[0].Fitness
+	archive.Update(pop)
+	chosen := gp.NoveltySelect(gp.Tournament(len(pop)*10), archive, 1).Select(pop, 1)
+	if chosen[0] != pop[0] || pop[0].Fitness != fitness {
+		t.Errorf("expecting most novel individual to be selected: novelty=%.3g", pop[0].Novelty)
+	}
+}
+
+// test MAP-Elites run over size and depth
+func TestElites(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(num.Add, num.Sub, num.Mul, num.Div, num.Neg, num.V(0), num.V(1))
+	problem := gp.Model{
+		PrimitiveSet:  pset,
+		Generator:     gp.GenRamped(pset, 1, 3),
+		PopSize:       100,
+		Fitness:       getFitness,
+		Offspring:     gp.Tournament(3),
+		Mutate:        gp.MutUniform(gp.GenGrow(pset, 0, 2)),
+		MutateProb:    0.5,
+		Crossover:     gp.CxOnePoint(),
+		CrossoverProb: 0.5,
+		Threads:       1,
+	}
+	elites := gp.NewElites(gp.SizeDim(1, 41, 10), gp.DepthDim(0, 8, 8))
+	logger := &stats.Logger{MaxGen: 10, TargetFitness: 2, Elites: elites}
+	pop := problem.RunElites(elites, logger)
+	t.Logf("%s: %d elites coverage=%.3f best=%s", elites, len(pop), elites.Coverage(), pop.Best())
+	if len(pop) != elites.Len() || elites.Coverage() < 0.1 {
+		t.Error("expecting more elites")
+	}
+	for _, ind := range pop {
+		cell := elites.Cell(ind)
+		size, depth := (ind.Size()-1)/4, ind.Depth()
+		if size > 9 {
+			size = 9
+		}
+		if depth > 7 {
+			depth = 7
+		}
+		if elites.Get(cell...) != ind || cell[0] != size || cell[1] != depth {
+			t.Fatalf("individual in wrong cell %v: size=%d depth=%d", cell, ind.Size(), ind.Depth())
+		}
+	}
+	var buf bytes.Buffer
+	if err := elites.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var data struct {
+		Dims   []struct{ Name string }
+		Elites []gp.EliteResult
+	}
+	if err := json.Unmarshal(buf.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Dims) != 2 || data.Dims[1].Name != "Depth" || len(data.Elites) != len(pop) || data.Elites[0].Code != pop[0].Code.Format() {
+		t.Errorf("invalid JSON data: %s", buf.String()[:200])
+	}
+	if plot := stats.ElitesPlot(elites, 500, 400)(pop); !bytes.Contains(plot, []byte("<svg")) {
+		t.Error("invalid SVG plot")
+	}
+}
+
+// contest between two constant expressions where the larger value wins
+func larger(a, b gp.Expr) (float64, float64) {
+	va, vb := a.Eval().(num.V), b.Eval().(num.V)
+	switch {
+	case va > vb:
+		return 1, 0
+	case va < vb:
+		return 0, 1
+	}
+	return 0.5, 0.5
+}
+
+func TestPairing(t *testing.T) {
+	gp.SetSeed(1)
+	players := []gp.Expr{{num.V(1)}, {num.V(2)}, {num.V(3)}, {num.V(4)}}
+	fit := gp.AllVsAll().Play(players, players[:3], larger)
+	t.Log("AllVsAll:", fit)
+	if math.Abs(fit[0]-0.5/3) > 1e-9 || math.Abs(fit[1]-1.5/3) > 1e-9 || math.Abs(fit[2]-2.5/3) > 1e-9 || fit[3] != 1 {
+		t.Error("incorrect AllVsAll fitness")
+	}
+	fit = gp.RandomK(5).Play(players, players[:2], larger)
+	t.Log("RandomK:", fit)
+	if fit[0] > 0.5 || fit[2] != 1 || fit[3] != 1 {
+		t.Error("incorrect RandomK fitness")
+	}
+	fit = gp.KnockoutTournament().Play(players, nil, larger)
+	t.Log("KnockoutTournament:", fit)
+	if fit[3] != 1 || fit[0]+fit[1]+fit[2] != 0.5 {
+		t.Error("incorrect KnockoutTournament fitness")
+	}
+}
+
+// logger which records the number of evaluations for each generation
+type evalsLogger struct {
+	maxGen int
+	evals  []int
+}
+
+func (l *evalsLogger) Log(pop gp.Population, gen, evals int) bool {
+	l.evals = append(l.evals, evals)
+	return gen >= l.maxGen
+}
+
+func TestCoevolution(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(num.Add, num.Sub, num.Mul, num.Div, num.Neg, num.V(0), num.V(1))
+	species := func() *gp.Model {
+		return &gp.Model{
+			PrimitiveSet:  pset,
+			Generator:     gp.GenRamped(pset, 1, 3),
+			PopSize:       50,
+			Offspring:     gp.Tournament(3),
+			Mutate:        gp.MutUniform(gp.GenGrow(pset, 0, 2)),
+			MutateProb:    0.2,
+			Crossover:     gp.CxOnePoint(),
+			CrossoverProb: 0.5,
+			Threads:       1,
+		}
+	}
+	// competitive: closest fit to the target wins each game
+	contest := func(a, b gp.Expr) (float64, float64) {
+		fitA, _ := getFitness(a)
+		fitB, _ := getFitness(b)
+		return larger(gp.Expr{num.V(fitA)}, gp.Expr{num.V(fitB)})
+	}
+	for _, pairing := range []gp.Pairing{gp.AllVsAll(), gp.RandomK(5), gp.KnockoutTournament()} {
+		coev := gp.Competitive(contest, pairing, 5, species())
+		pops := coev.Run(&stats.Logger{MaxGen: 10, TargetFitness: 2})
+		hof := coev.HallOfFame(0)
+		t.Logf("%s: best=%s", pairing, coev.Best()[0])
+		if len(pops) != 1 || len(pops[0]) != 50 || len(hof) != 5 {
+			t.Fatal("invalid populations")
+		}
+		f0, f1 := 0.0, 0.0
+		for _, ind := range hof {
+			fit, _ := getFitness(ind.Code)
+			f0 = math.Max(f0, fit)
+		}
+		for _, ind := range pops[0] {
+			fit, _ := getFitness(ind.Code)
+			f1 = math.Max(f1, fit)
+		}
+		if f1 < f0 {
+			t.Errorf("%s: expecting fitness to improve: %.3g => %.3g", pairing, f0, f1)
+		}
+	}
+	// cooperative: sum of expressions from two species is fitted to the target
+	team := func(team []gp.Expr) float64 {
+		fit, _ := getFitness(append(append(gp.Expr{num.Add}, team[0]...), team[1]...))
+		return fit
+	}
+	coev := gp.Cooperative(team, 1, species(), species())
+	coev.Species[0].PopSize, coev.Species[1].PopSize = 100, 100
+	pops := coev.Run(&stats.Logger{MaxGen: 20, TargetFitness: 0.99}, &stats.Logger{MaxGen: 20, TargetFitness: 0.99})
+	best := coev.Best()
+	fit := team([]gp.Expr{best[0].Code, best[1].Code})
+	t.Logf("team: %s + %s => %.3g", best[0].Code.Format(), best[1].Code.Format(), fit)
+	if len(pops) != 2 || fit < 0.5 {
+		t.Errorf("expecting better team fitness: %.3g", fit)
+	}
+	// evals is the number of contests played or teams scored
+	logger := &evalsLogger{maxGen: 1}
+	gp.Competitive(contest, gp.AllVsAll(), 5, species()).Run(logger)
+	if len(logger.evals) != 2 || logger.evals[0] != 50*50 || logger.evals[1] != 50*51 {
+		t.Error("invalid evals for AllVsAll", logger.evals)
+	}
+	logger = &evalsLogger{}
+	gp.Cooperative(team, 1, species(), species()).Run(logger, &evalsLogger{})
+	if logger.evals[0] != 2*50 {
+		t.Error("invalid evals for cooperative", logger.evals)
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic with one logger for two species")
+		}
+	}()
+	coev.Run(logger)
+}
@@ -0,0 +1,164 @@
+package gp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// A BehaviourEvaluator is an Evaluator which can also return a behaviour descriptor for the
+// code, e.g. the final position of an agent, which is used to calculate the novelty.
+// Population.Evaluate stores the descriptor in the Behaviour field of each individual.
+type BehaviourEvaluator interface {
+	Evaluator
+	GetBehaviour(code Expr) (fit float64, ok bool, behaviour []float64)
+}
+
+// The GetBehaviour method is provided so that the Model type implements the BehaviourEvaluator
+// interface. If the Behaviour function is not set then the behaviour is nil.
+func (m *Model) GetBehaviour(code Expr) (float64, bool, []float64) {
+	if m.Behaviour != nil {
+		return m.Behaviour(code)
+	}
+	fit, ok := m.Fitness(code)
+	return fit, ok, nil
+}
+
+// An Archive holds the behaviours of novel individuals found during a novelty search. The
+// novelty of an individual is the mean distance between its behaviour and the K nearest
+// neighbours from the population and the archive. Individuals with novelty greater than
+// Threshold are added to the archive. The threshold is raised if more than 4 individuals are
+// added in a generation and lowered if none are added for 5 generations. If MaxSize is non zero
+// the oldest entries are removed once the archive is full.
+type Archive struct {
+	K          int
+	Threshold  float64
+	MaxSize    int
+	behaviours [][]float64
+	added      int
+	stale      int
+}
+
+// NewArchive creates a new empty archive.
+func NewArchive(k int, threshold float64, maxSize int) *Archive {
+	return &Archive{K: k, Threshold: threshold, MaxSize: maxSize}
+}
+
+// String returns the archive parameters.
+func (a *Archive) String() string {
+	return fmt.Sprintf("Archive(k=%d, threshold=%g, max=%d)", a.K, a.Threshold, a.MaxSize)
+}
+
+// Len returns the number of behaviours in the archive.
+func (a *Archive) Len() int {
+	return len(a.behaviours)
+}
+
+// Added returns the number of behaviours added by the last call to Update.
+func (a *Archive) Added() int {
+	return a.added
+}
+
+// Update calculates the novelty of each individual in the population and adds those which
+// are sufficiently novel to the archive. Individuals with no behaviour have zero novelty.
+func (a *Archive) Update(pop Population) {
+	archived := len(a.behaviours)
+	for _, ind := range pop {
+		ind.Novelty = 0
+		if ind.Behaviour == nil {
+			continue
+		}
+		dist := make([]float64, 0, len(pop)+archived)
+		for _, other := range pop {
+			if other != ind && other.Behaviour != nil {
+				dist = append(dist, distance(ind.Behaviour, other.Behaviour))
+			}
+		}
+		for _, b := range a.behaviours[:archived] {
+			dist = append(dist, distance(ind.Behaviour, b))
+		}
+		sort.Float64s(dist)
+		if len(dist) > a.K {
+			dist = dist[:a.K]
+		}
+		for _, d := range dist {
+			ind.Novelty += d / float64(len(dist))
+		}
+		if ind.Novelty > a.Threshold {
+			a.behaviours = append(a.behaviours, ind.Behaviour)
+		}
+	}
+	a.added = len(a.behaviours) - archived
+	if a.MaxSize > 0 && len(a.behaviours) > a.MaxSize {
+		a.behaviours = a.behaviours[len(a.behaviours)-a.MaxSize:]
+	}
+	if a.added > 4 {
+		a.Threshold *= 1.2
+	}
+	if a.added == 0 {
+		if a.stale++; a.stale >= 5 {
+			a.Threshold *= 0.95
+			a.stale = 0
+		}
+	} else {
+		a.stale = 0
+	}
+}
+
+// euclidean distance between two behaviours
+func distance(a, b []float64) float64 {
+	sum := 0.0
+	for i := 0; i < len(a) && i < len(b); i++ {
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(sum)
+}
+
+// novelty selector wraps another selector
+type noveltySel struct {
+	sel     Selector
+	archive *Archive
+	weight  float64
+}
+
+// NoveltySelect returns a selector which applies sel using a score which blends the fitness
+// with the novelty, scaled by the maximum in the population so it is from 0 to 1:
+// score = (1-weight)*fitness + weight*novelty. With a weight of 1 selection is on novelty alone.
+// Model.RunFrom updates the archive and the novelty of each individual after each generation
+// is evaluated, and the Behaviour function should be set in the model.
+func NoveltySelect(sel Selector, archive *Archive, weight float64) Selector {
+	return noveltySel{sel, archive, weight}
+}
+
+func (s noveltySel) String() string {
+	return fmt.Sprintf("NoveltySelect(%s,%s,%g)", s.sel, s.archive, s.weight)
+}
+
+// fitness is temporarily replaced by the score while the wrapped selector is applied
+func (s noveltySel) Select(pop Population, num int) Population {
+	fitness := make([]float64, len(pop))
+	maxNovelty := 0.0
+	for i, ind := range pop {
+		fitness[i] = ind.Fitness
+		maxNovelty = math.Max(maxNovelty, ind.Novelty)
+	}
+	for i, ind := range pop {
+		novelty := 0.0
+		if maxNovelty > 0 {
+			novelty = ind.Novelty / maxNovelty
+		}
+		ind.Fitness = (1-s.weight)*fitness[i] + s.weight*novelty
+	}
+	chosen := s.sel.Select(pop, num)
+	for i, ind := range pop {
+		ind.Fitness = fitness[i]
+	}
+	return chosen
+}
+
+// update the novelty archive if the model is using novelty selection
+func (m *Model) updateNovelty(pop Population) {
+	if s, ok := m.Offspring.(noveltySel); ok {
+		s.archive.Update(pop)
+	}
+}
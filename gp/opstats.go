@@ -0,0 +1,51 @@
+package gp
+
+import (
+	"strings"
+)
+
+// OpStats has the counts for a variation or decorator in a single generation. Applied is the
+// number of times the operation was used, Rejected is the number of children which were
+// discarded by a decorator and the remaining children are classed as Better, Worse or Neutral
+// by comparing their fitness with the best of their parents.
+type OpStats struct {
+	Applied, Rejected, Better, Worse, Neutral int
+}
+
+// OpStats returns the stats for each variation and decorator used to produce the individuals
+// which were evaluated in the last call to Evaluate. Where more than one variation is applied,
+// e.g. crossover followed by mutation, then each of them is counted.
+func (pop Population) OpStats() map[string]*OpStats {
+	ops := map[string]*OpStats{}
+	get := func(name string) *OpStats {
+		if ops[name] == nil {
+			ops[name] = &OpStats{}
+		}
+		return ops[name]
+	}
+	for _, ind := range pop {
+		for i := 0; i+1 < len(ind.rejects); i += 2 {
+			get(ind.rejects[i]).Applied++
+			get(ind.rejects[i]).Rejected++
+			get(ind.rejects[i+1]).Rejected++
+		}
+		if !ind.fresh || len(ind.Parents) == 0 {
+			continue
+		}
+		for _, name := range strings.Split(ind.Op, "+") {
+			s := get(name)
+			s.Applied++
+			switch {
+			case !ind.FitnessValid:
+				s.Worse++
+			case ind.Fitness > ind.parentFit:
+				s.Better++
+			case ind.Fitness < ind.parentFit:
+				s.Worse++
+			default:
+				s.Neutral++
+			}
+		}
+	}
+	return ops
+}
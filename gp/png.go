@@ -0,0 +1,99 @@
+package gp
+
+import (
+	"bytes"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Colors which can be used by name in NodeAttrs when rendering to PNG, else use #rrggbb format.
+var ColorNames = map[string]color.Color{
+	"black":     color.Black,
+	"white":     color.White,
+	"grey":      color.Gray{0x80},
+	"gray":      color.Gray{0x80},
+	"lightgrey": color.Gray{0xd3},
+	"lightgray": color.Gray{0xd3},
+	"darkgrey":  color.Gray{0xa9},
+	"darkgray":  color.Gray{0xa9},
+	"red":       color.RGBA{0xff, 0, 0, 0xff},
+	"green":     color.RGBA{0, 0x80, 0, 0xff},
+	"blue":      color.RGBA{0, 0, 0xff, 0xff},
+	"yellow":    color.RGBA{0xff, 0xff, 0, 0xff},
+	"orange":    color.RGBA{0xff, 0xa5, 0, 0xff},
+	"lightblue": color.RGBA{0xad, 0xd8, 0xe6, 0xff},
+}
+
+// convert color name to color, returns nil for none or if not recognised
+func getColor(name string) color.Color {
+	if strings.HasPrefix(name, "#") && len(name) == 7 {
+		if rgb, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff}
+		}
+	}
+	return ColorNames[strings.ToLower(name)]
+}
+
+// render as PNG image. Labels use the fixed size bitmap font from the golang.org/x/image package.
+func (t *tree) png() ([]byte, error) {
+	s := t.style
+	w, h := int(math.Ceil(s.scale*(t.width+NodeSep))), int(math.Ceil(s.scale*t.height))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.White, image.ZP, draw.Src)
+	for _, n := range t.nodes {
+		x1, y1 := t.center(n)
+		for _, c := range n.children {
+			x2, y2 := t.center(c)
+			drawLine(img, x1, y1, x2, y2, color.Black)
+		}
+	}
+	face := basicfont.Face7x13
+	for _, n := range t.nodes {
+		x, y := t.center(n)
+		drawEllipse(img, x, y, s.scale*n.width/2, s.scale*NodeHeight/2, getColor(s.fill), getColor(s.stroke))
+		col := getColor(s.color)
+		if col == nil {
+			col = color.Black
+		}
+		d := font.Drawer{Dst: img, Src: image.NewUniform(col), Face: face}
+		width := d.MeasureString(n.label)
+		d.Dot = fixed.Point26_6{X: fixed.Int26_6(x*64) - width/2, Y: fixed.I(int(y) + face.Ascent/2 - 1)}
+		d.DrawString(n.label)
+	}
+	var b bytes.Buffer
+	err := png.Encode(&b, img)
+	return b.Bytes(), err
+}
+
+// draw a line from x1,y1 to x2,y2
+func drawLine(img draw.Image, x1, y1, x2, y2 float64, col color.Color) {
+	steps := math.Max(math.Abs(x2-x1), math.Abs(y2-y1))
+	for i := 0.0; i <= steps; i++ {
+		f := i / math.Max(steps, 1)
+		img.Set(int(x1+f*(x2-x1)), int(y1+f*(y2-y1)), col)
+	}
+}
+
+// draw an ellipse centered on x,y with given fill and outline colours, which may be nil
+func drawEllipse(img draw.Image, x, y, rx, ry float64, fill, stroke color.Color) {
+	for py := int(y - ry - 1); py <= int(y+ry+1); py++ {
+		for px := int(x - rx - 1); px <= int(x+rx+1); px++ {
+			dx, dy := (float64(px)+0.5-x)/rx, (float64(py)+0.5-y)/ry
+			r := math.Sqrt(dx*dx + dy*dy)
+			edge := 1 / math.Min(rx, ry)
+			if r <= 1 && r > 1-edge && stroke != nil {
+				img.Set(px, py, stroke)
+			} else if r <= 1 && fill != nil {
+				img.Set(px, py, fill)
+			}
+		}
+	}
+}
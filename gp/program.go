@@ -0,0 +1,21 @@
+package gp
+
+// A Program is an opcode which holds a complete program in an alternative representation to the
+// expression tree, such as a linear or cartesian GP genome. The code for an individual is an
+// Expr with the program as the only node, so evaluating the code will run the program with the
+// given inputs and the existing selection, logging and stats can be used unchanged.
+// The Size and Depth of the individual are taken from the program.
+type Program interface {
+	Opcode
+	Size() int
+	Depth() int
+}
+
+// Program returns the program if the expression consists of a single Program opcode.
+func (e Expr) Program() (Program, bool) {
+	if len(e) != 1 {
+		return nil, false
+	}
+	p, ok := e[0].(Program)
+	return p, ok
+}
@@ -0,0 +1,252 @@
+package gp
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Layout parameters for the native tree renderer, in points at 72 DPI.
+var (
+	NodeHeight = 18.0
+	NodeSep    = 8.0
+	LevelSep   = 36.0
+	CharWidth  = 0.6
+)
+
+// A Renderer draws the tree for an expression as an image in the given format, e.g. "svg".
+type Renderer interface {
+	Render(e Expr, format string) ([]byte, error)
+	String() string
+}
+
+// DefaultRenderer is used by Expr.Render. It is set to use Graphviz if the dot program is found
+// in the path, else to the native tree renderer.
+var DefaultRenderer Renderer
+
+func init() {
+	if _, err := exec.LookPath("dot"); err == nil {
+		DefaultRenderer = DotRenderer("expr")
+	} else {
+		DefaultRenderer = TreeRenderer()
+	}
+}
+
+// Render draws the expression tree in the given format using the DefaultRenderer.
+func (e Expr) Render(format string) ([]byte, error) {
+	return DefaultRenderer.Render(e, format)
+}
+
+type dotRenderer struct{ name string }
+
+// DotRenderer returns a renderer which builds a graph with the given name using Expr.Graph
+// and lays it out with the Graphviz dot program. Any output format supported by dot may be used.
+func DotRenderer(name string) Renderer {
+	return dotRenderer{name}
+}
+
+func (r dotRenderer) Render(e Expr, format string) ([]byte, error) {
+	return Layout(e.Graph(r.name), format)
+}
+
+func (r dotRenderer) String() string { return "DotRenderer" }
+
+type treeRenderer struct{}
+
+// TreeRenderer returns a renderer which lays out the expression tree natively using a
+// Reingold-Tilford style algorithm. It does not require Graphviz and supports the "svg" and
+// "png" formats. Node styling is taken from NodeAttrs and the scale from GraphDPI.
+// PNG output depends on the "golang.org/x/image" module for its bitmap font.
+func TreeRenderer() Renderer {
+	return treeRenderer{}
+}
+
+func (r treeRenderer) String() string { return "TreeRenderer" }
+
+func (r treeRenderer) Render(e Expr, format string) ([]byte, error) {
+	if len(e) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	t := newTree(e)
+	switch format {
+	case "svg":
+		return t.svg(), nil
+	case "png":
+		return t.png()
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// tree node with position for drawing
+type treeNode struct {
+	label       string
+	x, y, width float64
+	children    []*treeNode
+	left, right []float64
+}
+
+// laid out tree
+type tree struct {
+	nodes         []*treeNode
+	width, height float64
+	style         nodeStyle
+}
+
+// style attributes from NodeAttrs
+type nodeStyle struct {
+	font                string
+	fontSize, scale     float64
+	fill, stroke, color string
+}
+
+func getStyle() nodeStyle {
+	s := nodeStyle{font: "Helvetica", fontSize: 10, scale: 1, fill: "none", stroke: "black", color: "black"}
+	attr := func(key string) (string, bool) {
+		val, ok := NodeAttrs[key]
+		return strings.Trim(val, `"`), ok
+	}
+	if font, ok := attr("fontname"); ok {
+		s.font = font
+	}
+	if size, ok := attr("fontsize"); ok {
+		if val, err := strconv.ParseFloat(size, 64); err == nil {
+			s.fontSize = val
+		}
+	}
+	if color, ok := attr("color"); ok {
+		s.stroke = color
+	}
+	if style, ok := attr("style"); ok && strings.Contains(style, "filled") {
+		s.fill = s.stroke
+		if color, ok := attr("fillcolor"); ok {
+			s.fill = color
+		}
+	}
+	if color, ok := attr("fontcolor"); ok {
+		s.color = color
+	}
+	if dpi, err := strconv.ParseFloat(GraphDPI, 64); err == nil && dpi > 0 {
+		s.scale = dpi / 72
+	}
+	return s
+}
+
+// build tree from expression and calculate node positions
+func newTree(e Expr) *tree {
+	t := &tree{style: getStyle()}
+	var build func(depth int) *treeNode
+	pos := -1
+	build = func(depth int) *treeNode {
+		pos++
+		op := e[pos]
+		n := &treeNode{label: op.String(), y: float64(depth) * LevelSep}
+		n.width = math.Max(NodeHeight*1.5, float64(len(n.label))*CharWidth*t.style.fontSize+NodeHeight)
+		t.nodes = append(t.nodes, n)
+		for i := 0; i < op.Arity(); i++ {
+			n.children = append(n.children, build(depth+1))
+		}
+		return n
+	}
+	root := build(0)
+	root.layout()
+	root.x = -minValue(root.left)
+	root.setPosition(t)
+	for _, n := range t.nodes {
+		t.height = math.Max(t.height, n.y)
+	}
+	t.height += NodeHeight + NodeSep
+	return t
+}
+
+// calculate offset of each child relative to the parent and the left and right contour at each level
+func (n *treeNode) layout() {
+	n.left = []float64{-n.width / 2}
+	n.right = []float64{n.width / 2}
+	if len(n.children) == 0 {
+		return
+	}
+	var left, right []float64
+	offset := make([]float64, len(n.children))
+	for i, c := range n.children {
+		c.layout()
+		if i > 0 {
+			// place as close as possible to the subtrees already placed
+			offset[i] = math.Inf(-1)
+			for l := 0; l < len(right) && l < len(c.left); l++ {
+				offset[i] = math.Max(offset[i], right[l]+NodeSep-c.left[l])
+			}
+		}
+		for l := range c.left {
+			if l >= len(left) {
+				left = append(left, offset[i]+c.left[l])
+			}
+			if l >= len(right) {
+				right = append(right, offset[i]+c.right[l])
+			} else {
+				right[l] = offset[i] + c.right[l]
+			}
+		}
+	}
+	// center parent over the children
+	mid := (offset[0] + offset[len(offset)-1]) / 2
+	for i, c := range n.children {
+		c.x = offset[i] - mid
+	}
+	for l := range left {
+		n.left = append(n.left, left[l]-mid)
+		n.right = append(n.right, right[l]-mid)
+	}
+}
+
+// convert relative to absolute positions
+func (n *treeNode) setPosition(t *tree) {
+	t.width = math.Max(t.width, n.x+n.width/2)
+	for _, c := range n.children {
+		c.x += n.x
+		c.setPosition(t)
+	}
+}
+
+func minValue(list []float64) float64 {
+	min := list[0]
+	for _, val := range list {
+		min = math.Min(min, val)
+	}
+	return min
+}
+
+// offset of node center from top left of image
+func (t *tree) center(n *treeNode) (x, y float64) {
+	return t.style.scale * (n.x + NodeSep/2), t.style.scale * (n.y + (NodeHeight+NodeSep)/2)
+}
+
+// render as SVG document
+func (t *tree) svg() []byte {
+	var b bytes.Buffer
+	s := t.style
+	w, h := s.scale*(t.width+NodeSep), s.scale*t.height
+	fmt.Fprintf(&b, `<?xml version="1.0"?>
+<svg width="%.0fpt" height="%.0fpt" viewBox="0 0 %.2f %.2f" xmlns="http://www.w3.org/2000/svg">
+<g font-family="%s" font-size="%.2f" text-anchor="middle">
+`, w, h, w, h, html.EscapeString(s.font), s.scale*s.fontSize)
+	for _, n := range t.nodes {
+		x1, y1 := t.center(n)
+		for _, c := range n.children {
+			x2, y2 := t.center(c)
+			fmt.Fprintf(&b, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\" stroke=\"black\"/>\n", x1, y1, x2, y2)
+		}
+	}
+	for _, n := range t.nodes {
+		x, y := t.center(n)
+		fmt.Fprintf(&b, "<ellipse cx=\"%.2f\" cy=\"%.2f\" rx=\"%.2f\" ry=\"%.2f\" fill=\"%s\" stroke=\"%s\"/>\n",
+			x, y, s.scale*n.width/2, s.scale*NodeHeight/2, html.EscapeString(s.fill), html.EscapeString(s.stroke))
+		fmt.Fprintf(&b, "<text x=\"%.2f\" y=\"%.2f\" fill=\"%s\">%s</text>\n",
+			x, y+s.scale*s.fontSize/3, html.EscapeString(s.color), html.EscapeString(n.label))
+	}
+	b.WriteString("</g>\n</svg>\n")
+	return b.Bytes()
+}
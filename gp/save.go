@@ -0,0 +1,114 @@
+package gp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A ConstantParser is an optional interface for an EphemeralConstant, or another opcode which
+// holds some values, which can convert the text returned by its String method back to an opcode.
+// It is used when reading a saved population.
+type ConstantParser interface {
+	Parse(text string) (Opcode, error)
+}
+
+// Write saves the population to w in text format, one individual per line. Each line has the
+// fitness followed by the opcodes in prefix order, with each opcode written as name/arity.
+// Programs such as linear or cartesian GP genomes have no text encoding so return an error.
+func (pop Population) Write(w io.Writer) error {
+	for _, ind := range pop {
+		if p, ok := ind.Code.Program(); ok {
+			return fmt.Errorf("cannot save %s: programs cannot be written as text", p)
+		}
+	}
+	bw := bufio.NewWriter(w)
+	for _, ind := range pop {
+		if ind.FitnessValid {
+			fmt.Fprint(bw, ind.Fitness)
+		} else {
+			fmt.Fprint(bw, "?")
+		}
+		for _, op := range ind.Code {
+			fmt.Fprintf(bw, "\t%s/%d", op, op.Arity())
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// ReadPopulation reads a population saved with Population.Write. Opcodes are looked up by name
+// and arity in the primitive set, and any other terminals must be accepted by the Parse method of
+// one of the constants. Any extra parsers, e.g. for opcodes added by a Hook, are also tried and
+// may return opcodes with any arity. Blank lines and lines starting with # are ignored.
+func ReadPopulation(r io.Reader, pset *PrimSet, extra ...ConstantParser) (pop Population, err error) {
+	ops := map[string]Opcode{}
+	parsers := []ConstantParser{}
+	for _, op := range append(pset.Terminals, pset.Primitives...) {
+		ops[fmt.Sprintf("%s/%d", op, op.Arity())] = op
+		if p, ok := op.(ConstantParser); ok {
+			parsers = append(parsers, p)
+		}
+	}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		ind := &Individual{Id: newId()}
+		if fields[0] != "?" {
+			if ind.Fitness, err = strconv.ParseFloat(fields[0], 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid fitness %q", line, fields[0])
+			}
+			ind.FitnessValid = true
+		}
+		for _, token := range fields[1:] {
+			op, ok := ops[token]
+			if !ok {
+				pos := strings.LastIndex(token, "/")
+				if pos < 0 {
+					return nil, fmt.Errorf("line %d: unknown opcode %q", line, token)
+				}
+				list := extra
+				if token[pos+1:] == "0" {
+					list = append(parsers, extra...)
+				}
+				for _, p := range list {
+					op, err = p.Parse(token[:pos])
+					if err == nil && op != nil && token[pos+1:] == strconv.Itoa(op.Arity()) {
+						break
+					}
+					op = nil
+				}
+				if op == nil {
+					return nil, fmt.Errorf("line %d: unknown opcode %q", line, token)
+				}
+			}
+			ind.Code = append(ind.Code, op)
+		}
+		if !ind.Code.valid() {
+			return nil, fmt.Errorf("line %d: invalid expression", line)
+		}
+		pop = append(pop, ind)
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	return pop, nil
+}
+
+// check that expression is a single complete tree
+func (e Expr) valid() bool {
+	need := 1
+	for _, op := range e {
+		if need <= 0 {
+			return false
+		}
+		need += op.Arity() - 1
+	}
+	return need == 0
+}
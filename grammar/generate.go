@@ -0,0 +1,201 @@
+package grammar
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"math/rand"
+)
+
+// CODON_MAX is the upper limit on the value of randomly generated codons.
+var CODON_MAX = 256
+
+type generator struct {
+	g        *Grammar
+	min, max int
+	method   string
+}
+
+// GenFull returns a generator which produces trees from the grammar start symbol where every
+// branch is extended to a depth between min and max where the grammar allows it.
+func (g *Grammar) GenFull(min, max int) gp.Generator {
+	return generator{g, min, max, "Full"}
+}
+
+// GenGrow returns a generator which produces trees from the grammar start symbol with depth
+// of at least min, where possible, and no more than max.
+func (g *Grammar) GenGrow(min, max int) gp.Generator {
+	return generator{g, min, max, "Grow"}
+}
+
+// GenRamped returns a generator which uses either the GenFull or GenGrow algorithm with
+// equal probability.
+func (g *Grammar) GenRamped(min, max int) gp.Generator {
+	return generator{g, min, max, "Ramped"}
+}
+
+func (gen generator) String() string {
+	return fmt.Sprintf("GrammarGen%s(%d,%d)", gen.method, gen.min, gen.max)
+}
+
+func (gen generator) Generate() *gp.Individual {
+	height := rand.Intn(1+gen.max-gen.min) + gen.min
+	grow := gen.method == "Grow" || (gen.method == "Ramped" && rand.Float64() >= 0.5)
+	ind := gp.Create(gen.g.expand(gp.Expr{}, gen.g.Start, 0, height, gen.min, grow))
+	ind.Op = gp.Init
+	return ind
+}
+
+// append a random derivation from symbol nt to code. Rules are chosen so that the tree depth is
+// no more than height if possible. If grow is not set, or depth is less than min, then rules
+// with arguments are chosen in preference to terminals.
+func (g *Grammar) expand(code gp.Expr, nt string, depth, height, min int, grow bool) gp.Expr {
+	choices := []*rule{}
+	for _, r := range g.flat[nt] {
+		if depth+r.depth <= height {
+			choices = append(choices, r)
+		}
+	}
+	if len(choices) == 0 {
+		for _, r := range g.flat[nt] {
+			if r.depth == g.depth[nt] {
+				choices = append(choices, r)
+			}
+		}
+	}
+	if !grow || depth < min {
+		branches := []*rule{}
+		for _, r := range choices {
+			if len(r.args) > 0 {
+				branches = append(branches, r)
+			}
+		}
+		if len(branches) > 0 && depth < height {
+			choices = branches
+		}
+	}
+	r := choices[rand.Intn(len(choices))]
+	op := r.op
+	if erc, ok := op.(gp.EphemeralConstant); ok {
+		op = erc.Init()
+	}
+	code = append(code, op)
+	for _, arg := range r.args {
+		code = g.expand(code, arg, depth+1, height, min, grow)
+	}
+	return code
+}
+
+// Mutate returns a mutation variation which replaces a random subtree with a new tree derived
+// from the same nonterminal symbol using the grow method with depth between min and max.
+func (g *Grammar) Mutate(min, max int) gp.Variation {
+	return gp.NewVariation(fmt.Sprintf("GrammarMut(%d,%d)", min, max), func(in gp.Population) gp.Population {
+		code := in[0].Code
+		labels := g.Labels(code)
+		if labels == nil {
+			return in
+		}
+		pos := rand.Intn(len(code))
+		height := rand.Intn(1+max-min) + min
+		in[0] = gp.Create(code.ReplaceSubtree(pos, g.expand(gp.Expr{}, labels[pos], 0, height, min, true)))
+		return in
+	})
+}
+
+// Crossover returns a crossover variation which swaps a random subtree in the first individual
+// with a subtree in the second which was derived from the same nonterminal symbol.
+func (g *Grammar) Crossover() gp.Variation {
+	return gp.NewVariation("GrammarCx", func(in gp.Population) gp.Population {
+		labels1, labels2 := g.Labels(in[0].Code), g.Labels(in[1].Code)
+		if labels1 == nil || labels2 == nil {
+			return in
+		}
+		pos1 := rand.Intn(len(labels1))
+		matches := []int{}
+		for i, label := range labels2 {
+			if label == labels1[pos1] {
+				matches = append(matches, i)
+			}
+		}
+		if len(matches) == 0 {
+			return in
+		}
+		pos2 := matches[rand.Intn(len(matches))]
+		subtree1 := in[0].Code[pos1 : in[0].Code.Traverse(pos1, nil, nil)+1].Clone()
+		subtree2 := in[1].Code[pos2 : in[1].Code.Traverse(pos2, nil, nil)+1].Clone()
+		in[0] = gp.Create(in[0].Code.ReplaceSubtree(pos1, subtree2))
+		in[1] = gp.Create(in[1].Code.ReplaceSubtree(pos2, subtree1))
+		return in
+	})
+}
+
+// Map converts a genome of integer codons to an expression using the grammatical evolution
+// mapping. Starting from the start symbol the leftmost nonterminal is expanded using the
+// alternative given by the next codon modulo the number of alternatives. Codons are not used
+// for symbols with a single alternative. If the codons run out the genome is reused up to wraps
+// times, after which the mapping fails and ok is false. Ephemeral constants are initialised
+// with a new random value.
+func (g *Grammar) Map(codons []int, wraps int) (code gp.Expr, ok bool) {
+	if len(codons) == 0 {
+		return nil, false
+	}
+	stack := []string{g.Start}
+	used := 0
+	for len(stack) > 0 {
+		nt := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		rules := g.rules[nt]
+		r := rules[0]
+		if len(rules) > 1 {
+			if used >= len(codons)*(wraps+1) {
+				return nil, false
+			}
+			r = rules[codons[used%len(codons)]%len(rules)]
+			used++
+		}
+		if r.op != nil {
+			op := r.op
+			if erc, ok := op.(gp.EphemeralConstant); ok {
+				op = erc.Init()
+			}
+			code = append(code, op)
+		}
+		for i := len(r.args) - 1; i >= 0; i-- {
+			stack = append(stack, r.args[i])
+		}
+	}
+	return code, true
+}
+
+type genCodons struct {
+	g             *Grammar
+	length, wraps int
+}
+
+// GenCodons returns a generator which maps a random genome of length codons to an expression
+// using Map. If no valid mapping is found after a number of attempts then a tree with the
+// minimum depth is generated instead.
+func (g *Grammar) GenCodons(length, wraps int) gp.Generator {
+	return genCodons{g, length, wraps}
+}
+
+func (gen genCodons) String() string {
+	return fmt.Sprintf("GrammarGenCodons(%d,%d)", gen.length, gen.wraps)
+}
+
+func (gen genCodons) Generate() *gp.Individual {
+	codons := make([]int, gen.length)
+	for try := 0; try < 100; try++ {
+		for i := range codons {
+			codons[i] = rand.Intn(CODON_MAX)
+		}
+		if code, ok := gen.g.Map(codons, gen.wraps); ok {
+			ind := gp.Create(code)
+			ind.Op = gp.Init
+			return ind
+		}
+	}
+	depth := gen.g.depth[gen.g.Start]
+	ind := gp.Create(gen.g.expand(gp.Expr{}, gen.g.Start, 0, depth, 0, true))
+	ind.Op = gp.Init
+	return ind
+}
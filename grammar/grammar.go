@@ -0,0 +1,304 @@
+// Package grammar provides grammar guided genetic programming for gogp. A grammar in BNF notation
+// restricts the shape of the expression trees which are generated, and crossover and mutation
+// operators are provided which only produce trees which are valid for the grammar. The result
+// of each derivation is a gp.Expr so evaluation, formatting and stats work as for standard GP.
+package grammar
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"io"
+	"math"
+	"strings"
+)
+
+// minimum depth of a symbol which has no finite derivation
+const unbounded = math.MaxInt32
+
+// A Grammar is a set of production rules mapping each nonterminal symbol to a list of
+// alternatives. Each alternative is either a single nonterminal, or an opcode name followed by
+// one nonterminal for each of the opcode arguments. For example:
+//
+//	<expr> ::= + <expr> <expr> | * <expr> <expr> | <term>
+//	<term> ::= x | erc
+//
+// Opcodes are looked up by name and number of arguments in the primitive set. Ephemeral
+// constants are referred to as erc0, erc1 etc. in the order they were added, with erc as an
+// alias for the first. Lines starting with | continue the previous rule and # starts a comment.
+// The first rule defines the start symbol.
+type Grammar struct {
+	Start string
+	names []string
+	rules map[string][]*rule
+	flat  map[string][]*rule
+	depth map[string]int
+}
+
+// a rule is a single alternative: op is nil for a chain to another nonterminal
+type rule struct {
+	name  string
+	op    gp.Opcode
+	args  []string
+	depth int
+}
+
+func (r *rule) String() string {
+	list := append([]string{}, r.args...)
+	for i, arg := range list {
+		list[i] = "<" + arg + ">"
+	}
+	if r.op != nil {
+		list = append([]string{r.name}, list...)
+	}
+	return strings.Join(list, " ")
+}
+
+// named is implemented by ephemeral constants which can return the name they were created with
+type named interface {
+	Name() string
+}
+
+// check if opcode in an expression could be generated from the rule. Values from an ephemeral
+// constant must be of the same type and have the same name if this is available.
+func (r *rule) matches(op gp.Opcode) bool {
+	if op.Arity() != len(r.args) {
+		return false
+	}
+	if _, ok := r.op.(gp.EphemeralConstant); ok {
+		if fmt.Sprintf("%T", op) != fmt.Sprintf("%T", r.op) {
+			return false
+		}
+		n1, ok1 := op.(named)
+		n2, ok2 := r.op.(named)
+		return !ok1 || !ok2 || n1.Name() == n2.Name()
+	}
+	return op.String() == r.op.String()
+}
+
+// Parse creates a new grammar from the text definition using the opcodes in pset.
+func Parse(text string, pset *gp.PrimSet) (*Grammar, error) {
+	return Read(strings.NewReader(text), pset)
+}
+
+// Read reads a grammar definition from r using the opcodes in pset.
+func Read(r io.Reader, pset *gp.PrimSet) (*Grammar, error) {
+	ops := map[string]gp.Opcode{}
+	nerc := 0
+	for _, op := range append(pset.Terminals, pset.Primitives...) {
+		ops[fmt.Sprintf("%s/%d", op, op.Arity())] = op
+		if _, ok := op.(gp.EphemeralConstant); ok {
+			if nerc == 0 {
+				ops["erc/0"] = op
+			}
+			ops[fmt.Sprintf("erc%d/0", nerc)] = op
+			nerc++
+		}
+	}
+	g := &Grammar{rules: map[string][]*rule{}}
+	s := bufio.NewScanner(r)
+	current := ""
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if pos := strings.Index(text, "#"); pos >= 0 {
+			text = text[:pos]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "|") {
+			if current == "" {
+				return nil, fmt.Errorf("line %d: no rule to continue", line)
+			}
+			text = text[1:]
+		} else {
+			fields := strings.SplitN(text, "::=", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: expecting <symbol> ::= alternatives", line)
+			}
+			var ok bool
+			if current, ok = symbol(strings.TrimSpace(fields[0])); !ok {
+				return nil, fmt.Errorf("line %d: invalid symbol %q", line, fields[0])
+			}
+			if _, exists := g.rules[current]; exists {
+				return nil, fmt.Errorf("line %d: duplicate rule for <%s>", line, current)
+			}
+			if g.Start == "" {
+				g.Start = current
+			}
+			g.names = append(g.names, current)
+			g.rules[current] = []*rule{}
+			text = fields[1]
+		}
+		for _, alt := range strings.Split(text, "|") {
+			tokens := strings.Fields(alt)
+			if len(tokens) == 0 {
+				continue
+			}
+			r := &rule{}
+			if nt, ok := symbol(tokens[0]); ok {
+				if len(tokens) > 1 {
+					return nil, fmt.Errorf("line %d: alternative %q should start with an opcode", line, alt)
+				}
+				r.args = []string{nt}
+			} else {
+				for _, token := range tokens[1:] {
+					nt, ok := symbol(token)
+					if !ok {
+						return nil, fmt.Errorf("line %d: expecting nonterminal argument, got %q", line, token)
+					}
+					r.args = append(r.args, nt)
+				}
+				r.name = tokens[0]
+				key := fmt.Sprintf("%s/%d", r.name, len(r.args))
+				if r.op, ok = ops[key]; !ok {
+					return nil, fmt.Errorf("line %d: opcode %s not in primitive set", line, key)
+				}
+			}
+			g.rules[current] = append(g.rules[current], r)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if g.Start == "" {
+		return nil, fmt.Errorf("grammar has no rules")
+	}
+	return g, g.init()
+}
+
+// returns the name if token is a nonterminal in angle brackets
+func symbol(token string) (string, bool) {
+	if len(token) > 2 && strings.HasPrefix(token, "<") && strings.HasSuffix(token, ">") {
+		return token[1 : len(token)-1], true
+	}
+	return "", false
+}
+
+// check all symbols are defined, expand chain rules and calculate the minimum depth for each rule
+func (g *Grammar) init() error {
+	for _, name := range g.names {
+		for _, r := range g.rules[name] {
+			for _, arg := range r.args {
+				if _, ok := g.rules[arg]; !ok {
+					return fmt.Errorf("symbol <%s> used in rule for <%s> is not defined", arg, name)
+				}
+			}
+		}
+	}
+	g.flat = map[string][]*rule{}
+	for _, name := range g.names {
+		seen := map[string]bool{}
+		var expand func(nt string)
+		expand = func(nt string) {
+			seen[nt] = true
+			for _, r := range g.rules[nt] {
+				if r.op != nil {
+					g.flat[name] = append(g.flat[name], r)
+				} else if !seen[r.args[0]] {
+					expand(r.args[0])
+				}
+			}
+		}
+		expand(name)
+	}
+	g.depth = map[string]int{}
+	for _, name := range g.names {
+		g.depth[name] = unbounded
+		for _, r := range g.flat[name] {
+			r.depth = unbounded
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, name := range g.names {
+			for _, r := range g.flat[name] {
+				depth := 0
+				for _, arg := range r.args {
+					if g.depth[arg] >= unbounded {
+						depth = unbounded
+						break
+					}
+					if g.depth[arg]+1 > depth {
+						depth = g.depth[arg] + 1
+					}
+				}
+				if depth < r.depth {
+					r.depth, changed = depth, true
+				}
+				if depth < g.depth[name] {
+					g.depth[name] = depth
+				}
+			}
+		}
+	}
+	for _, name := range g.names {
+		if g.depth[name] >= unbounded {
+			return fmt.Errorf("no finite derivation for symbol <%s>", name)
+		}
+	}
+	return nil
+}
+
+// String returns the grammar in BNF notation.
+func (g *Grammar) String() string {
+	lines := []string{}
+	for _, name := range g.names {
+		alts := []string{}
+		for _, r := range g.rules[name] {
+			alts = append(alts, r.String())
+		}
+		lines = append(lines, fmt.Sprintf("<%s> ::= %s", name, strings.Join(alts, " | ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MinDepth returns the minimum depth of a tree derived from the nonterminal symbol.
+func (g *Grammar) MinDepth(symbol string) int {
+	return g.depth[symbol]
+}
+
+// Labels returns the nonterminal symbol from which each node in the expression was derived, or
+// nil if the expression is not valid for the grammar.
+func (g *Grammar) Labels(code gp.Expr) []string {
+	if len(code) == 0 {
+		return nil
+	}
+	labels := make([]string, len(code))
+	labels[0] = g.Start
+	if g.derive(code, g.Start, 0, labels) != len(code)-1 {
+		return nil
+	}
+	return labels
+}
+
+// Valid returns true if the expression can be derived from the grammar.
+func (g *Grammar) Valid(code gp.Expr) bool {
+	return g.Labels(code) != nil
+}
+
+// derive the subtree starting at pos from symbol nt, returns the end position or -1 if no match.
+func (g *Grammar) derive(code gp.Expr, nt string, pos int, labels []string) int {
+	if pos >= len(code) {
+		return -1
+	}
+	for _, r := range g.flat[nt] {
+		if !r.matches(code[pos]) {
+			continue
+		}
+		end := pos
+		for _, arg := range r.args {
+			if end+1 < len(labels) {
+				labels[end+1] = arg
+			}
+			if end = g.derive(code, arg, end+1, labels); end < 0 {
+				break
+			}
+		}
+		if end >= 0 {
+			return end
+		}
+	}
+	return -1
+}
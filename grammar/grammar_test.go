@@ -0,0 +1,131 @@
+package grammar
+
+import (
+	"github.com/jnb666/gogp/gp"
+	"github.com/jnb666/gogp/num"
+	"testing"
+)
+
+// polynomial in x with integer coefficients, e.g. (3 * x) + ((2 * (x * x)) - 1)
+const POLY = `
+<poly>  ::= + <poly> <poly> | - <poly> <poly>
+          | <term>
+<term>  ::= * <coeff> <power> | <coeff>   # coefficient times power of x
+<power> ::= <x> | * <x> <power>
+<coeff> ::= erc | - <coeff>
+<x>     ::= x
+`
+
+var erc = num.Ephemeral("ERC", func() num.V { return 3 })
+
+func setup(t *testing.T) *Grammar {
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(num.Add, num.Sub, num.Mul, num.Neg, erc)
+	g, err := Parse(POLY, pset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("grammar:\n%s", g)
+	return g
+}
+
+// test parsing the grammar and checking expressions
+func TestParse(t *testing.T) {
+	g := setup(t)
+	if g.Start != "poly" || g.MinDepth("poly") != 0 || g.MinDepth("power") != 0 {
+		t.Errorf("invalid grammar: start=%s depth=%d", g.Start, g.MinDepth("poly"))
+	}
+	x := gp.CreatePrimSet(1, "x").Var(0)
+	three := erc.Init()
+	valid := gp.Expr{num.Add, num.Mul, num.Neg, three, num.Mul, x, x, three}
+	if labels := g.Labels(valid); labels == nil || labels[2] != "coeff" || labels[4] != "power" || labels[5] != "x" {
+		t.Errorf("invalid labels for %s: %v", valid.Format(), labels)
+	}
+	for _, code := range []gp.Expr{{x}, {num.Mul, x, three}, {num.Neg, x}, {num.Add, three}} {
+		if g.Valid(code) {
+			t.Errorf("%v should not be valid", code)
+		}
+	}
+	// ephemeral constants of the same type are distinguished by name
+	small := num.Ephemeral("small", func() num.V { return 1 })
+	pset2 := gp.CreatePrimSet(1, "x")
+	pset2.Add(num.Add, erc, small)
+	g2, err := Parse("<a> ::= + <b> <c>\n<b> ::= erc0\n<c> ::= erc1", pset2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g2.Valid(gp.Expr{num.Add, three, small.Init()}) || g2.Valid(gp.Expr{num.Add, small.Init(), three}) {
+		t.Error("ephemeral constants should be matched by name")
+	}
+	errors := []string{
+		"<a> ::= + <a> <a>",
+		"<a> ::= x | <b>",
+		"<a> ::= foo",
+		"<a> ::= + <a>",
+		"| x",
+		"",
+	}
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(num.Add)
+	for _, text := range errors {
+		if _, err := Parse(text, pset); err == nil {
+			t.Errorf("expecting error parsing %q", text)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+// test the generators and variations only produce valid trees
+func TestVariation(t *testing.T) {
+	gp.SetSeed(1)
+	g := setup(t)
+	for _, gen := range []gp.Generator{g.GenFull(2, 4), g.GenGrow(1, 3), g.GenRamped(2, 5), g.GenCodons(20, 2)} {
+		pop := gp.CreatePopulation(50, gen)
+		for i := 0; i < 10; i++ {
+			pop = gp.VarAnd(pop, g.Crossover(), g.Mutate(0, 2), 0.5, 0.5)
+		}
+		for _, ind := range pop {
+			if !g.Valid(ind.Code) {
+				t.Fatalf("%s: invalid tree %s", gen, ind.Code.Format())
+			}
+			if _, ok := ind.Code.Eval(num.V(2)).(num.V); !ok {
+				t.Fatalf("%s: error evaluating %s", gen, ind.Code.Format())
+			}
+		}
+		t.Logf("%s: %s", gen, pop[0].Code.Format())
+	}
+	full := g.GenFull(3, 3).Generate()
+	if full.Depth() < 3 {
+		t.Errorf("expecting depth 3 tree from GenFull: %s", full.Code.Format())
+	}
+}
+
+// test grammatical evolution mapping
+func TestMap(t *testing.T) {
+	g := setup(t)
+	tests := []struct {
+		codons []int
+		wraps  int
+		format string
+	}{
+		{[]int{2, 1, 0}, 0, "3"},
+		{[]int{2, 1}, 1, "3"},
+		{[]int{0, 2, 0, 0, 0, 2, 1, 0}, 0, "((3 * x) + 3)"},
+		{[]int{1, 2, 0, 0, 1, 0, 2, 1, 1, 0}, 0, "((3 * (x * x)) - -(3))"},
+		{[]int{0, 0}, 2, ""},
+	}
+	for _, test := range tests {
+		code, ok := g.Map(test.codons, test.wraps)
+		if !ok {
+			if test.format != "" {
+				t.Errorf("mapping failed for %v", test.codons)
+			}
+			continue
+		}
+		t.Logf("%v => %s", test.codons, code.Format())
+		if code.Format() != test.format || !g.Valid(code) {
+			t.Errorf("expected %s", test.format)
+		}
+	}
+}
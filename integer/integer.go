@@ -0,0 +1,185 @@
+// Package integer provides a 64 bit integer type and associated operations for gogp.
+package integer
+
+import (
+	"github.com/jnb666/gogp/gp"
+	"strconv"
+)
+
+var (
+	Add = Op("+", func(a, b V) V { return a + b })
+	Sub = Op("-", func(a, b V) V { return a - b })
+	Mul = Op("*", func(a, b V) V { return a * b })
+	Div = Op("/", protected_divide)
+	Mod = Op("%", protected_modulo)
+	Neg = Unary("-", func(a V) V { return -a })
+	Abs = Unary("abs", func(a V) V {
+		if a < 0 {
+			return -a
+		}
+		return a
+	})
+	Min = Func("min", 2, func(a []V) V {
+		if a[0] < a[1] {
+			return a[0]
+		}
+		return a[1]
+	})
+	Max = Func("max", 2, func(a []V) V {
+		if a[0] > a[1] {
+			return a[0]
+		}
+		return a[1]
+	})
+)
+
+// Bitwise operations. Shift counts are taken modulo 64.
+var (
+	And = Op("&", func(a, b V) V { return a & b })
+	Or  = Op("|", func(a, b V) V { return a | b })
+	Xor = Op("^", func(a, b V) V { return a ^ b })
+	Not = gp.Formatted(Unary("not", func(a V) V { return ^a }), "~%s")
+	Shl = Op("<<", func(a, b V) V { return a << (uint64(b) & 63) })
+	Shr = Op(">>", func(a, b V) V { return a >> (uint64(b) & 63) })
+)
+
+// Comparison primitives: IfLT, IfLTE and IfEq return the third argument if the comparison
+// of the first two is true, else the fourth. IfZero and IfNeg test a single value and return
+// the second or third argument.
+var (
+	IfLT   = gp.Formatted(Func("iflt", 4, func(a []V) V { return choose(a[0] < a[1], a[2], a[3]) }), "(%s < %s ? %s : %s)")
+	IfLTE  = gp.Formatted(Func("iflte", 4, func(a []V) V { return choose(a[0] <= a[1], a[2], a[3]) }), "(%s <= %s ? %s : %s)")
+	IfEq   = gp.Formatted(Func("ifeq", 4, func(a []V) V { return choose(a[0] == a[1], a[2], a[3]) }), "(%s == %s ? %s : %s)")
+	IfZero = gp.Formatted(Func("ifzero", 3, func(a []V) V { return choose(a[0] == 0, a[1], a[2]) }), "(%s == 0 ? %s : %s)")
+	IfNeg  = gp.Formatted(Func("ifneg", 3, func(a []V) V { return choose(a[0] < 0, a[1], a[2]) }), "(%s < 0 ? %s : %s)")
+)
+
+func choose(cond bool, a, b V) V {
+	if cond {
+		return a
+	}
+	return b
+}
+
+// divide by zero returns zero
+func protected_divide(a, b V) V {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// modulo zero returns zero, else the result has the same sign as b
+func protected_modulo(a, b V) V {
+	if b == 0 {
+		return 0
+	}
+	m := a % b
+	if m != 0 && (m < 0) != (b < 0) {
+		m += b
+	}
+	return m
+}
+
+// V is an integer value which implements the gp.Opcode interface
+type V int64
+
+// Arity method returns the number of arguments for the opcode
+func (n V) Arity() int { return 0 }
+
+// Eval method for an integer operator returns the value
+func (n V) Eval(args ...gp.Value) gp.Value { return n }
+
+// String method returns the name of the opcode
+func (n V) String() string { return strconv.FormatInt(int64(n), 10) }
+
+// Format method is called by Expr Format() to return a expression in a human readable format
+func (n V) Format(args ...string) string { return n.String() }
+
+// Ephemeral constructor to create an integer EphemeralConstant
+func Ephemeral(name string, gen func() V) gp.EphemeralConstant {
+	return erc{gen: gen, name: name}
+}
+
+type erc struct {
+	V
+	gen  func() V
+	name string
+}
+
+func (e erc) Init() gp.EphemeralConstant {
+	return erc{e.gen(), e.gen, e.name}
+}
+
+// Name returns the name of the ephemeral constant which generated the value.
+func (e erc) Name() string { return e.name }
+
+// Parse returns a constant with the value given by text, used when reading a saved population.
+func (e erc) Parse(text string) (gp.Opcode, error) {
+	val, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return erc{V(val), e.gen, e.name}, nil
+}
+
+// Func constructor returns an integer function with given arity
+// which implements the gp.Opcode interface
+func Func(name string, arity int, fun func([]V) V) gp.Opcode {
+	return intFunc{gp.Function(name, arity), fun}
+}
+
+type intFunc struct {
+	gp.Opcode
+	fun func([]V) V
+}
+
+func (o intFunc) Eval(iargs ...gp.Value) gp.Value {
+	args := make([]V, len(iargs))
+	for i, iarg := range iargs {
+		args[i] = iarg.(V)
+	}
+	return o.fun(args)
+}
+
+// Term constructor returns an integer terminal operator which implements the gp.Opcode interface
+func Term(name string, fun func() V) gp.Opcode {
+	return termOp{gp.Terminal(name), fun}
+}
+
+type termOp struct {
+	gp.Opcode
+	fun func() V
+}
+
+func (o termOp) Eval(args ...gp.Value) gp.Value {
+	return o.fun()
+}
+
+// Unary constructor returns an integer unary operator which implements the gp.Opcode interface
+func Unary(name string, fun func(a V) V) gp.Opcode {
+	return unaryOp{gp.Function(name, 1), fun}
+}
+
+type unaryOp struct {
+	gp.Opcode
+	fun func(a V) V
+}
+
+func (o unaryOp) Eval(args ...gp.Value) gp.Value {
+	return o.fun(args[0].(V))
+}
+
+// Op constructor returns an integer binary operator which implements the gp.Opcode interface
+func Op(name string, fun func(a, b V) V) gp.Opcode {
+	return intOp{gp.Operator(name), fun}
+}
+
+type intOp struct {
+	gp.Opcode
+	fun func(a, b V) V
+}
+
+func (o intOp) Eval(args ...gp.Value) gp.Value {
+	return o.fun(args[0].(V), args[1].(V))
+}
@@ -0,0 +1,59 @@
+package integer
+
+import (
+	"bytes"
+	"github.com/jnb666/gogp/gp"
+	"math/rand"
+	"testing"
+)
+
+// test evaluating expressions
+func TestEval(t *testing.T) {
+	pset := gp.CreatePrimSet(2, "x", "y")
+	x, y := pset.Var(0), pset.Var(1)
+	tests := []struct {
+		code   gp.Expr
+		format string
+		result V
+	}{
+		{gp.Expr{Add, Mul, x, y, V(3)}, "((x * y) + 3)", 33},
+		{gp.Expr{Div, x, Sub, y, y}, "(x / (y - y))", 0},
+		{gp.Expr{Mod, Neg, x, y}, "(-(x) % y)", 2},
+		{gp.Expr{Mod, x, V(0)}, "(x % 0)", 0},
+		{gp.Expr{Xor, Shl, x, V(2), Not, y}, "((x << 2) ^ ~y)", (10 << 2) ^ ^3},
+		{gp.Expr{Shr, x, V(65)}, "(x >> 65)", 5},
+		{gp.Expr{IfLT, x, y, V(1), V(2)}, "(x < y ? 1 : 2)", 2},
+		{gp.Expr{IfZero, Sub, x, V(10), Abs, Neg, y, V(0)}, "((x - 10) == 0 ? abs(-(y)) : 0)", 3},
+		{gp.Expr{Max, And, x, y, Or, x, y}, "max((x & y), (x | y))", 11},
+	}
+	for _, test := range tests {
+		val := test.code.Eval(V(10), V(3)).(V)
+		format := test.code.Format()
+		t.Logf("%s = %d", format, val)
+		if format != test.format || val != test.result {
+			t.Errorf("expected %s = %d", test.format, test.result)
+		}
+	}
+}
+
+// test generating, saving and reading back a population with integer constants
+func TestSave(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(Add, Sub, Mul, Div, Mod, IfLTE)
+	pset.Add(Ephemeral("ERC", func() V { return V(rand.Intn(100) - 50) }))
+	pop := gp.CreatePopulation(20, gp.GenRamped(pset, 1, 3))
+	var buf bytes.Buffer
+	if err := pop.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pop2, err := gp.ReadPopulation(&buf, pset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range pop {
+		if pop[i].Code.Format() != pop2[i].Code.Format() || pop[i].Code.Eval(V(7)) != pop2[i].Code.Eval(V(7)) {
+			t.Error("population read back does not match", pop[i], pop2[i])
+		}
+	}
+}
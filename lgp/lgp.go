@@ -0,0 +1,318 @@
+// Package lgp provides linear genetic programming for gogp. A program is a sequence of register
+// machine instructions, each of which applies an opcode from the primitive set to calculation,
+// input or constant registers and writes the result to a calculation register. The calculation
+// registers are initialised from the inputs and the output is the final value of register r0.
+// Programs implement the gp.Program interface so the standard selection, logging and stats
+// can be used.
+package lgp
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"math/rand"
+	"strings"
+)
+
+// A Machine defines the register machine for a run: the opcodes and input variables are taken
+// from the primitive set, and any other terminals are used as constant registers. Registers is
+// the number of calculation registers and MinLen and MaxLen limit the number of instructions.
+type Machine struct {
+	PrimSet   *gp.PrimSet
+	Registers int
+	MinLen    int
+	MaxLen    int
+	consts    []gp.Opcode
+}
+
+// NewMachine creates a new register machine definition.
+func NewMachine(pset *gp.PrimSet, registers, minLen, maxLen int) *Machine {
+	if registers < 1 {
+		registers = 1
+	}
+	return &Machine{pset, registers, minLen, maxLen, pset.Terminals[pset.NumVars:]}
+}
+
+// String returns the machine parameters.
+func (m *Machine) String() string {
+	return fmt.Sprintf("Machine(registers=%d, length=%d-%d)", m.Registers, m.MinLen, m.MaxLen)
+}
+
+// an instruction sets register dst to the result of applying op to the src registers. Registers
+// are numbered with the calculation registers first, then the inputs, then the constants.
+type instr struct {
+	op  gp.Opcode
+	dst int
+	src []int
+}
+
+// program implements the gp.Program interface
+type program struct {
+	m         *Machine
+	code      []instr
+	consts    []gp.Opcode
+	effective []bool
+}
+
+// create a new program and flag the instructions which can affect the output
+func newProgram(m *Machine, code []instr, consts []gp.Opcode) *program {
+	p := &program{m: m, code: code, consts: consts, effective: make([]bool, len(code))}
+	live := make([]bool, m.Registers)
+	live[0] = true
+	for i := len(code) - 1; i >= 0; i-- {
+		if live[code[i].dst] {
+			p.effective[i] = true
+			live[code[i].dst] = false
+			for _, src := range code[i].src {
+				if src < m.Registers {
+					live[src] = true
+				}
+			}
+		}
+	}
+	return p
+}
+
+// get the program from the code for an individual, ok is false if it is some other expression
+func getProgram(code gp.Expr) (p *program, ok bool) {
+	if len(code) == 1 {
+		p, ok = code[0].(*program)
+	}
+	return
+}
+
+// returns a copy of the program code which can be modified
+func (p *program) clone() []instr {
+	code := make([]instr, len(p.code))
+	for i, in := range p.code {
+		code[i] = instr{in.op, in.dst, append([]int{}, in.src...)}
+	}
+	return code
+}
+
+func (p *program) Arity() int { return 0 }
+
+// Eval runs the program with the given input values and returns the value of register r0.
+func (p *program) Eval(input ...gp.Value) gp.Value {
+	regs := make([]gp.Value, p.m.Registers)
+	for i := range regs {
+		if len(input) > 0 {
+			regs[i] = input[i%len(input)]
+		} else if len(p.consts) > 0 {
+			regs[i] = p.consts[0].Eval()
+		}
+	}
+	for i, in := range p.code {
+		if !p.effective[i] {
+			continue
+		}
+		args := make([]gp.Value, len(in.src))
+		for j, src := range in.src {
+			switch {
+			case src < p.m.Registers:
+				args[j] = regs[src]
+			case src < p.m.Registers+p.m.PrimSet.NumVars:
+				args[j] = input[src-p.m.Registers]
+			default:
+				args[j] = p.consts[src-p.m.Registers-p.m.PrimSet.NumVars].Eval()
+			}
+		}
+		regs[in.dst] = in.op.Eval(args...)
+	}
+	return regs[0]
+}
+
+// name of register n
+func (p *program) register(n int) string {
+	switch {
+	case n < p.m.Registers:
+		return fmt.Sprintf("r%d", n)
+	case n < p.m.Registers+p.m.PrimSet.NumVars:
+		return p.m.PrimSet.Var(n - p.m.Registers).String()
+	default:
+		return p.consts[n-p.m.Registers-p.m.PrimSet.NumVars].Format()
+	}
+}
+
+// Format returns the effective instructions separated by semicolons. The last instruction
+// sets the output register.
+func (p *program) Format(args ...string) string {
+	list := []string{}
+	for i, in := range p.code {
+		if p.effective[i] {
+			names := make([]string, len(in.src))
+			for j, src := range in.src {
+				names[j] = p.register(src)
+			}
+			list = append(list, fmt.Sprintf("r%d = %s", in.dst, in.op.Format(names...)))
+		}
+	}
+	if len(list) == 0 {
+		return p.register(p.m.Registers)
+	}
+	return strings.Join(list, "; ")
+}
+
+func (p *program) String() string { return p.Format() }
+
+// Size returns the total number of instructions, including those which have no effect.
+func (p *program) Size() int { return len(p.code) }
+
+// Depth returns the length of the longest chain of effective instructions leading to the output.
+func (p *program) Depth() int {
+	depth := make([]int, p.m.Registers)
+	for i, in := range p.code {
+		if p.effective[i] {
+			max := 0
+			for _, src := range in.src {
+				if src < p.m.Registers && depth[src] > max {
+					max = depth[src]
+				}
+			}
+			depth[in.dst] = max + 1
+		}
+	}
+	return depth[0]
+}
+
+// random source register
+func (m *Machine) operand() int {
+	return rand.Intn(m.Registers + m.PrimSet.NumVars + len(m.consts))
+}
+
+// random instruction
+func (m *Machine) instruction() instr {
+	op := m.PrimSet.Primitives[rand.Intn(len(m.PrimSet.Primitives))]
+	in := instr{op, rand.Intn(m.Registers), make([]int, op.Arity())}
+	for i := range in.src {
+		in.src[i] = m.operand()
+	}
+	return in
+}
+
+// random constant value for entry i
+func (m *Machine) constant(i int) gp.Opcode {
+	if erc, ok := m.consts[i].(gp.EphemeralConstant); ok {
+		return erc.Init()
+	}
+	return m.consts[i]
+}
+
+type generator struct{ m *Machine }
+
+// Gen returns a generator which produces random programs with length between MinLen and MaxLen.
+func (m *Machine) Gen() gp.Generator {
+	return generator{m}
+}
+
+func (g generator) String() string {
+	return fmt.Sprintf("LgpGen(%d,%d)", g.m.MinLen, g.m.MaxLen)
+}
+
+func (g generator) Generate() *gp.Individual {
+	m := g.m
+	code := make([]instr, rand.Intn(1+m.MaxLen-m.MinLen)+m.MinLen)
+	for i := range code {
+		code[i] = m.instruction()
+	}
+	consts := make([]gp.Opcode, len(m.consts))
+	for i := range consts {
+		consts[i] = m.constant(i)
+	}
+	ind := gp.Create(gp.Expr{newProgram(m, code, consts)})
+	ind.Op = gp.Init
+	return ind
+}
+
+// MutMicro returns a mutation variation which changes either the opcode, the destination
+// register or one of the source registers of a random instruction, or the value of a constant.
+func (m *Machine) MutMicro() gp.Variation {
+	return gp.NewVariation("LgpMutMicro", func(in gp.Population) gp.Population {
+		p, ok := getProgram(in[0].Code)
+		if !ok || len(p.code) == 0 {
+			return in
+		}
+		code, consts := p.clone(), p.consts
+		in1 := &code[rand.Intn(len(code))]
+		switch rand.Intn(4) {
+		case 0:
+			in1.op = m.PrimSet.Primitives[rand.Intn(len(m.PrimSet.Primitives))]
+			for len(in1.src) < in1.op.Arity() {
+				in1.src = append(in1.src, m.operand())
+			}
+			in1.src = in1.src[:in1.op.Arity()]
+		case 1:
+			in1.dst = rand.Intn(m.Registers)
+		case 2:
+			if len(in1.src) > 0 {
+				in1.src[rand.Intn(len(in1.src))] = m.operand()
+			}
+		case 3:
+			if len(consts) > 0 {
+				consts = append([]gp.Opcode{}, consts...)
+				i := rand.Intn(len(consts))
+				consts[i] = m.constant(i)
+			}
+		}
+		in[0] = gp.Create(gp.Expr{newProgram(m, code, consts)})
+		return in
+	})
+}
+
+// MutMacro returns a mutation variation which either inserts a new random instruction or
+// deletes an existing instruction, keeping the length between MinLen and MaxLen.
+func (m *Machine) MutMacro() gp.Variation {
+	return gp.NewVariation("LgpMutMacro", func(in gp.Population) gp.Population {
+		p, ok := getProgram(in[0].Code)
+		if !ok {
+			return in
+		}
+		code := p.clone()
+		insert := rand.Float64() < 0.5
+		if len(code) <= m.MinLen || len(code) == 0 {
+			insert = true
+		}
+		if len(code) >= m.MaxLen {
+			insert = false
+		}
+		if !insert && (len(code) <= m.MinLen || len(code) == 0) {
+			return in
+		}
+		if insert {
+			pos := rand.Intn(len(code) + 1)
+			code = append(code[:pos], append([]instr{m.instruction()}, code[pos:]...)...)
+		} else {
+			pos := rand.Intn(len(code))
+			code = append(code[:pos], code[pos+1:]...)
+		}
+		in[0] = gp.Create(gp.Expr{newProgram(m, code, p.consts)})
+		return in
+	})
+}
+
+// Crossover returns a crossover variation which exchanges a random segment of instructions
+// between two programs, keeping the length of each between MinLen and MaxLen.
+func (m *Machine) Crossover() gp.Variation {
+	return gp.NewVariation("LgpCx", func(in gp.Population) gp.Population {
+		p1, ok1 := getProgram(in[0].Code)
+		p2, ok2 := getProgram(in[1].Code)
+		if !ok1 || !ok2 || len(p1.code) == 0 || len(p2.code) == 0 {
+			return in
+		}
+		for try := 0; try < 10; try++ {
+			start1, start2 := rand.Intn(len(p1.code)), rand.Intn(len(p2.code))
+			len1, len2 := 1+rand.Intn(len(p1.code)-start1), 1+rand.Intn(len(p2.code)-start2)
+			size1, size2 := len(p1.code)-len1+len2, len(p2.code)-len2+len1
+			if size1 < m.MinLen || size1 > m.MaxLen || size2 < m.MinLen || size2 > m.MaxLen {
+				continue
+			}
+			code1, code2 := p1.clone(), p2.clone()
+			seg1, seg2 := code1[start1:start1+len1], code2[start2:start2+len2]
+			child1 := append(append(append([]instr{}, code1[:start1]...), seg2...), code1[start1+len1:]...)
+			child2 := append(append(append([]instr{}, code2[:start2]...), seg1...), code2[start2+len2:]...)
+			in[0] = gp.Create(gp.Expr{newProgram(m, child1, p1.consts)})
+			in[1] = gp.Create(gp.Expr{newProgram(m, child2, p2.consts)})
+			break
+		}
+		return in
+	})
+}
@@ -0,0 +1,72 @@
+package lgp
+
+import (
+	"github.com/jnb666/gogp/gp"
+	"github.com/jnb666/gogp/num"
+	"io/ioutil"
+	"testing"
+)
+
+func setup() *Machine {
+	pset := gp.CreatePrimSet(2, "x", "y")
+	pset.Add(num.Add, num.Sub, num.Mul, num.Neg, num.V(1), num.Ephemeral("ERC", func() num.V { return 2 }))
+	return NewMachine(pset, 3, 1, 10)
+}
+
+// test evaluating and formatting a program
+func TestEval(t *testing.T) {
+	m := setup()
+	// registers are r0,r1,r2, x,y, 1,ERC
+	code := []instr{
+		{num.Mul, 1, []int{3, 3}}, // r1 = x*x
+		{num.Add, 2, []int{1, 4}}, // r2 = r1+y - no effect
+		{num.Neg, 0, []int{6}},    // r0 = -2
+		{num.Add, 0, []int{0, 1}}, // r0 = r0 + r1
+		{num.Sub, 0, []int{0, 5}}, // r0 = r0 - 1
+	}
+	p := newProgram(m, code, []gp.Opcode{num.V(1), num.V(2)})
+	ind := gp.Create(gp.Expr{p})
+	val := ind.Code.Eval(num.V(3), num.V(4))
+	format := ind.Code.Format()
+	t.Logf("%s => %v", format, val)
+	if val != num.V(6) || format != "r1 = (x * x); r0 = -(2); r0 = (r0 + r1); r0 = (r0 - 1)" {
+		t.Error("invalid result")
+	}
+	if ind.Size() != 5 || ind.Depth() != 3 {
+		t.Errorf("expecting size 5 depth 3, got %d %d", ind.Size(), ind.Depth())
+	}
+	if err := (gp.Population{ind}).Write(ioutil.Discard); err == nil {
+		t.Error("expecting error writing a program")
+	}
+	if p := newProgram(m, code[:2], p.consts); p.Format() != "x" || p.Eval(num.V(3), num.V(4)) != num.V(3) {
+		t.Errorf("invalid result for program with no effective instructions: %s", p.Format())
+	}
+}
+
+// test the variation operators keep the program length within limits
+func TestVariation(t *testing.T) {
+	gp.SetSeed(1)
+	m := setup()
+	eval := &gp.Model{Fitness: func(code gp.Expr) (float64, bool) { return 0, true }}
+	pop := gp.CreatePopulation(50, m.Gen())
+	for i := 0; i < 20; i++ {
+		pop = gp.VarAnd(pop, m.Crossover(), m.MutMicro(), 0.5, 0.5)
+		pop, _ = gp.VarAnd(pop, m.Crossover(), m.MutMacro(), 0.5, 0.5).Evaluate(eval, 1)
+	}
+	for _, ind := range pop {
+		if ind.Size() < m.MinLen || ind.Size() > m.MaxLen {
+			t.Fatalf("invalid size %d: %s", ind.Size(), ind.Code.Format())
+		}
+		if _, ok := ind.Code.Eval(num.V(1), num.V(2)).(num.V); !ok {
+			t.Fatalf("error evaluating %s", ind.Code.Format())
+		}
+	}
+	t.Log(pop[0].Code.Format())
+	// code which is not a program is returned unchanged
+	tree := gp.Create(gp.Expr{num.Neg, num.V(1)})
+	for _, v := range []gp.Variation{m.Crossover(), m.MutMicro(), m.MutMacro()} {
+		if out := v.Variate(gp.Population{tree, pop[0]}); out[0].Id != tree.Id {
+			t.Errorf("%s: expected tree to be unchanged", v)
+		}
+	}
+}
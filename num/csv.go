@@ -0,0 +1,140 @@
+package num
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Missing sets how cases with missing values are handled when reading a dataset.
+type Missing int
+
+const (
+	DropMissing Missing = iota // skip any row with a missing value
+	MeanMissing                // replace missing inputs with the column mean, skip rows with missing target
+)
+
+// Strings which are treated as a missing value, in addition to an empty field.
+var MissingValues = []string{"NA", "N/A", "NaN", "nan", "null", "?"}
+
+// ReadCSV reads a dataset from r where the first line has the column names and subsequent lines
+// have the values for each case separated by sep. The target column is given by name, or if this
+// is blank the last column is used. The remaining columns are the inputs.
+func ReadCSV(r io.Reader, target string, sep rune, missing Missing) (*Dataset, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = sep
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %s", err)
+	}
+	targetCol := len(header) - 1
+	if target != "" {
+		targetCol = -1
+		for i, name := range header {
+			if strings.TrimSpace(name) == target {
+				targetCol = i
+			}
+		}
+		if targetCol < 0 {
+			return nil, fmt.Errorf("target column %q not found", target)
+		}
+	}
+	names := []string{}
+	for i, name := range header {
+		if i != targetCol {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	rows := [][]float64{}
+	valid := [][]bool{}
+	sum := make([]float64, len(header))
+	count := make([]int, len(header))
+	for line := 1; ; line++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row, ok := make([]float64, len(header)), make([]bool, len(header))
+		for i, field := range fields {
+			if isMissing(field) {
+				continue
+			}
+			if row[i], ok[i] = parseValue(field); !ok[i] {
+				return nil, fmt.Errorf("record %d: invalid value %q", line, field)
+			}
+			sum[i] += row[i]
+			count[i]++
+		}
+		rows, valid = append(rows, row), append(valid, ok)
+	}
+	d := NewDataset(names...)
+nextRow:
+	for r, row := range rows {
+		if !valid[r][targetCol] {
+			continue
+		}
+		inputs := []float64{}
+		for i, val := range row {
+			if i == targetCol {
+				continue
+			}
+			if !valid[r][i] {
+				if missing == DropMissing || count[i] == 0 {
+					continue nextRow
+				}
+				val = sum[i] / float64(count[i])
+			}
+			inputs = append(inputs, val)
+		}
+		d.Add(row[targetCol], inputs...)
+	}
+	return d, nil
+}
+
+// ReadFile reads a dataset from a file using ReadCSV. Fields are tab separated if the file
+// has a .tsv or .tab extension, else comma separated.
+func ReadFile(file, target string, missing Missing) (*Dataset, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sep := ','
+	if ext := filepath.Ext(file); ext == ".tsv" || ext == ".tab" {
+		sep = '\t'
+	}
+	return ReadCSV(f, target, sep, missing)
+}
+
+// PrimSet returns a new primitive set with a variable for each of the dataset inputs.
+func (d *Dataset) PrimSet() *gp.PrimSet {
+	return gp.CreatePrimSet(len(d.Names), d.Names...)
+}
+
+func parseValue(field string) (float64, bool) {
+	val, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+	return val, err == nil
+}
+
+func isMissing(field string) bool {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return true
+	}
+	for _, s := range MissingValues {
+		if field == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package num
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"math"
+	"math/rand"
+)
+
+// A Dataset holds a set of fitness cases for a regression problem. Each case has a list of
+// input values and a target value. Names are the names of the input variables.
+type Dataset struct {
+	Names  []string
+	Inputs [][]gp.Value
+	Target []float64
+}
+
+// NewDataset creates a new empty dataset with the given input variable names.
+func NewDataset(names ...string) *Dataset {
+	return &Dataset{Names: names, Inputs: [][]gp.Value{}, Target: []float64{}}
+}
+
+// Add appends a new case with given target and input values.
+func (d *Dataset) Add(target float64, inputs ...float64) {
+	in := make([]gp.Value, len(inputs))
+	for i, val := range inputs {
+		in[i] = V(val)
+	}
+	d.Inputs = append(d.Inputs, in)
+	d.Target = append(d.Target, target)
+}
+
+// Len returns the number of cases in the dataset.
+func (d *Dataset) Len() int {
+	return len(d.Target)
+}
+
+// String returns a summary of the dataset.
+func (d *Dataset) String() string {
+	return fmt.Sprintf("Dataset(%d cases, inputs %v)", d.Len(), d.Names)
+}
+
+// Shuffle returns a copy of the dataset with the cases in random order.
+func (d *Dataset) Shuffle() *Dataset {
+	s := NewDataset(d.Names...)
+	for _, i := range rand.Perm(d.Len()) {
+		s.Inputs = append(s.Inputs, d.Inputs[i])
+		s.Target = append(s.Target, d.Target[i])
+	}
+	return s
+}
+
+// Subset returns a dataset with the cases from start up to but not including end.
+// The cases are shared with the original dataset.
+func (d *Dataset) Subset(start, end int) *Dataset {
+	return &Dataset{Names: d.Names, Inputs: d.Inputs[start:end], Target: d.Target[start:end]}
+}
+
+// Split divides the dataset into consecutive parts, where each part has the given fraction of the
+// cases and the final part has the remainder, e.g. Split(0.6, 0.2) returns train, validation and
+// test sets in the ratio 60:20:20. Call Shuffle first if the cases are ordered.
+func (d *Dataset) Split(fractions ...float64) []*Dataset {
+	parts := []*Dataset{}
+	start := 0
+	for _, frac := range fractions {
+		end := start + int(frac*float64(d.Len())+0.5)
+		if end > d.Len() {
+			end = d.Len()
+		}
+		if end < start {
+			end = start
+		}
+		parts = append(parts, d.Subset(start, end))
+		start = end
+	}
+	return append(parts, d.Subset(start, d.Len()))
+}
+
+// KFold divides the dataset into k folds for cross validation. The ith test set is the ith
+// fold and the ith training set has the remaining cases.
+func (d *Dataset) KFold(k int) (train, test []*Dataset) {
+	for i := 0; i < k; i++ {
+		start, end := i*d.Len()/k, (i+1)*d.Len()/k
+		test = append(test, d.Subset(start, end))
+		t := NewDataset(d.Names...)
+		t.Inputs = append(append(t.Inputs, d.Inputs[:start]...), d.Inputs[end:]...)
+		t.Target = append(append(t.Target, d.Target[:start]...), d.Target[end:]...)
+		train = append(train, t)
+	}
+	return
+}
+
+// Outputs returns the result of evaluating the code for each case.
+func (d *Dataset) Outputs(code gp.Expr) []float64 {
+	return Semantics(code, d.Inputs)
+}
+
+// SSE returns the sum of squared errors between the code output and the target values.
+func (d *Dataset) SSE(code gp.Expr) float64 {
+	sum := 0.0
+	for i, val := range d.Outputs(code) {
+		sum += (val - d.Target[i]) * (val - d.Target[i])
+	}
+	return sum
+}
+
+// RMSE returns the root mean squared error between the code output and the target values.
+func (d *Dataset) RMSE(code gp.Expr) float64 {
+	return math.Sqrt(d.SSE(code) / float64(d.Len()))
+}
+
+// Fitness returns a normalised fitness from 0 to 1 calculated as 1/(1+SSE).
+// It can be used as the Fitness function in a gp.Model.
+func (d *Dataset) Fitness(code gp.Expr) (float64, bool) {
+	return 1 / (1 + d.SSE(code)), true
+}
+
+// Validate returns the fitness for the code, ignoring the valid flag. It can be used as the
+// Validate function for a stats.Logger.
+func (d *Dataset) Validate(code gp.Expr) float64 {
+	fit, _ := d.Fitness(code)
+	return fit
+}
+
+// LinearScaling returns a hook which scales the output of each individual to best fit the dataset.
+func (d *Dataset) LinearScaling() gp.Hook {
+	return LinearScaling(d.Inputs, d.Target)
+}
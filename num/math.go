@@ -0,0 +1,60 @@
+package num
+
+import (
+	"github.com/jnb666/gogp/gp"
+	"math"
+)
+
+// Protection thresholds for the math functions: Log returns 0 if the absolute value of the
+// argument is less than LOG_PROTECT, Exp limits the argument to EXP_LIMIT and Pow raises the
+// absolute value of its first argument, returning 1 if the result is not finite or exceeds POW_LIMIT.
+var (
+	LOG_PROTECT = 1e-10
+	EXP_LIMIT   = 100.0
+	POW_LIMIT   = 1e100
+)
+
+// Standard library of numeric primitives with protected semantics.
+var (
+	Sin     = Unary("sin", func(a V) V { return V(math.Sin(float64(a))) })
+	Cos     = Unary("cos", func(a V) V { return V(math.Cos(float64(a))) })
+	Tan     = Unary("tan", func(a V) V { return V(math.Tan(float64(a))) })
+	Tanh    = Unary("tanh", func(a V) V { return V(math.Tanh(float64(a))) })
+	Exp     = Unary("exp", protected_exp)
+	Log     = Unary("log", protected_log)
+	Sqrt    = Unary("sqrt", func(a V) V { return V(math.Sqrt(math.Abs(float64(a)))) })
+	Abs     = Unary("abs", func(a V) V { return V(math.Abs(float64(a))) })
+	Sigmoid = Unary("sigmoid", func(a V) V { return V(sigmoid(float64(a))) })
+	Square  = Unary("square", func(a V) V { return a * a })
+	Cube    = Unary("cube", func(a V) V { return a * a * a })
+	Pow     = Op("^", protected_pow)
+	Min     = Func("min", 2, func(a []V) V { return V(math.Min(float64(a[0]), float64(a[1]))) })
+	Max     = Func("max", 2, func(a []V) V { return V(math.Max(float64(a[0]), float64(a[1]))) })
+	IfLTE   = gp.Formatted(Func("iflte", 4, if_lte), "(%s <= %s ? %s : %s)")
+)
+
+func protected_exp(a V) V {
+	return V(math.Exp(math.Min(float64(a), EXP_LIMIT)))
+}
+
+func protected_log(a V) V {
+	if a > -V(LOG_PROTECT) && a < V(LOG_PROTECT) {
+		return 0
+	}
+	return V(math.Log(math.Abs(float64(a))))
+}
+
+func protected_pow(a, b V) V {
+	val := math.Pow(math.Abs(float64(a)), float64(b))
+	if math.IsNaN(val) || math.Abs(val) > POW_LIMIT {
+		return 1
+	}
+	return V(val)
+}
+
+func if_lte(a []V) V {
+	if a[0] <= a[1] {
+		return a[2]
+	}
+	return a[3]
+}
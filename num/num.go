@@ -4,9 +4,12 @@ package num
 import (
 	"fmt"
 	"github.com/jnb666/gogp/gp"
+	"strconv"
 )
 
-const DIVIDE_PROTECT = 1e-10
+// Protection thresholds: division by a value smaller than DIVIDE_PROTECT returns 0.
+// See also math.go for the other protected functions.
+var DIVIDE_PROTECT = 1e-10
 
 var (
 	Add = Op("+", func(a, b V) V { return a + b })
@@ -17,7 +20,7 @@ var (
 )
 
 func protected_divide(a, b V) V {
-	if b > -DIVIDE_PROTECT && b < DIVIDE_PROTECT {
+	if b > -V(DIVIDE_PROTECT) && b < V(DIVIDE_PROTECT) {
 		return 0
 	}
 	return V(a / b)
@@ -53,6 +56,18 @@ func (e erc) Init() gp.EphemeralConstant {
 	return erc{e.gen(), e.gen, e.name}
 }
 
+// Name returns the name of the ephemeral constant which generated the value.
+func (e erc) Name() string { return e.name }
+
+// Parse returns a constant with the value given by text, used when reading a saved population.
+func (e erc) Parse(text string) (gp.Opcode, error) {
+	val, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, err
+	}
+	return erc{V(val), e.gen, e.name}, nil
+}
+
 // Func constructor returns a numeric function with given arity
 // which implements the gp.Opcode interface
 func Func(name string, arity int, fun func([]V) V) gp.Opcode {
@@ -1,9 +1,12 @@
 package num
 
 import (
+	"bytes"
 	"github.com/jnb666/gogp/gp"
+	"image/png"
 	"math"
 	"math/rand"
+	"strings"
 	"testing"
 )
 
@@ -176,3 +179,324 @@ func TestGraph(t *testing.T) {
 	}
 	t.Log(string(data))
 }
+
+// test saving and restoring a population
+func TestSave(t *testing.T) {
+	pset := initPset(true)
+	pset.Add(Ephemeral("ERC", func() V { return V(rand.Intn(10)) / 4 }))
+	gp.SetSeed(1)
+	pop := gp.CreatePopulation(10, gp.GenRamped(pset, 1, 3))
+	pop[0].Fitness, pop[0].FitnessValid = 0.5, true
+	var buf bytes.Buffer
+	if err := pop.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(buf.String())
+	pop2, err := gp.ReadPopulation(&buf, pset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pop2) != len(pop) {
+		t.Fatalf("read %d individuals - expected %d", len(pop2), len(pop))
+	}
+	for i, ind := range pop2 {
+		if ind.String() != pop[i].String() {
+			t.Errorf("got %s - expected %s", ind, pop[i])
+		}
+	}
+	_, err = gp.ReadPopulation(strings.NewReader("?\t+/2\tx/0\n"), pset)
+	t.Log(err)
+	if err == nil {
+		t.Error("expected error for invalid expression")
+	}
+	_, err = gp.ReadPopulation(strings.NewReader("?\t+/2\tx/0\tz/0\n"), pset)
+	t.Log(err)
+	if err == nil {
+		t.Error("expected error for unknown opcode")
+	}
+}
+
+// test native tree rendering
+func TestRender(t *testing.T) {
+	pset := initPset(true)
+	exprs := testExprs(pset)
+	r := gp.TreeRenderer()
+	data, err := r.Render(exprs[2], "svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(string(data))
+	if n := strings.Count(string(data), "<ellipse"); n != len(exprs[2]) {
+		t.Errorf("got %d nodes - expected %d", n, len(exprs[2]))
+	}
+	data, err = r.Render(exprs[2], "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("png image size", img.Bounds())
+	if _, err = r.Render(exprs[2], "gif"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+// logger which stops after a fixed number of generations
+type genLogger struct {
+	maxGen int
+	best   []float64
+}
+
+func (l *genLogger) Log(pop gp.Population, gen, evals int) bool {
+	l.best = append(l.best, pop.Best().Fitness)
+	return gen >= l.maxGen
+}
+
+// test semantic and geometric semantic operators
+func TestSemantic(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(Add, Sub, Mul, Div, V(1))
+	inputs := [][]gp.Value{}
+	target := []float64{}
+	for x := -1.0; x <= 1.0; x += 0.1 {
+		inputs = append(inputs, []gp.Value{V(x)})
+		target = append(target, x*x*x+x*x+x)
+	}
+	fitness := func(code gp.Expr) (float64, bool) {
+		return 1 / (1 + SemanticDistance(Semantics(code, inputs), target)), true
+	}
+	gen := gp.GenGrow(pset, 1, 2)
+	for _, ops := range [][2]gp.Variation{
+		{SemanticCx(inputs, 0.01, 0.5, 10), SemanticMut(inputs, gen, 0.01, 10)},
+		{GeometricCx(inputs, gen), GeometricMut(inputs, gen, 0.1)},
+	} {
+		problem := &gp.Model{
+			PrimitiveSet:  pset,
+			Generator:     gp.GenRamped(pset, 1, 3),
+			PopSize:       100,
+			Fitness:       fitness,
+			Offspring:     gp.Tournament(3),
+			Mutate:        ops[1],
+			MutateProb:    0.3,
+			Crossover:     ops[0],
+			CrossoverProb: 0.7,
+			Threads:       2,
+		}
+		logger := &genLogger{maxGen: 20}
+		best := problem.Run(logger).Best()
+		t.Logf("%s %s: fitness %.3f -> %.3f %s", ops[0], ops[1], logger.best[0], best.Fitness, best.Code.Format())
+		if best.Fitness < logger.best[0] {
+			t.Error("fitness should not get worse")
+		}
+		sem := Semantics(best.Code, inputs)
+		for i, in := range inputs {
+			if val := float64(best.Code.Eval(in...).(V)); math.Abs(val-sem[i]) > 1e-9 {
+				t.Errorf("cached semantics %g does not match eval %g", sem[i], val)
+			}
+		}
+		// reusing the buffer for other test inputs should not return stale results
+		test := [][]gp.Value{{V(0.25)}, {V(2)}}
+		for _, x := range []float64{0.25, -3} {
+			test[0][0] = V(x)
+			for i, val := range Semantics(best.Code, test) {
+				if math.Abs(val-float64(best.Code.Eval(test[i]...).(V))) > 1e-9 {
+					t.Errorf("semantics for test inputs do not match eval")
+				}
+			}
+		}
+		if _, ok := best.Code.Program(); ok && (best.Size() <= 1 || best.Depth() <= 1) {
+			t.Errorf("expecting size of equivalent tree: size=%d depth=%d", best.Size(), best.Depth())
+		}
+	}
+}
+
+// test linear scaling hook
+func TestLinearScaling(t *testing.T) {
+	pset := gp.CreatePrimSet(1, "x")
+	inputs, target := [][]gp.Value{}, []float64{}
+	for x := -1.0; x <= 1.0; x += 0.25 {
+		inputs = append(inputs, []gp.Value{V(x)})
+		target = append(target, 3+2*x*x)
+	}
+	fitness := func(code gp.Expr) (float64, bool) {
+		return 1 / (1 + SemanticDistance(Semantics(code, inputs), target)), true
+	}
+	x := pset.Var(0)
+	pop := gp.Population{gp.Create(gp.Expr{Mul, x, x}), gp.Create(gp.Expr{V(1)})}
+	hook := LinearScaling(inputs, target)
+	pop, _ = pop.Evaluate(&gp.Model{Fitness: fitness}, 1, hook)
+	t.Log(pop[0], pop[1])
+	if pop[0].Fitness < 0.999999 || pop[0].Code.Format() != "(3 + 2 * (x * x))" {
+		t.Error("expected exact fit for scaled x*x")
+	}
+	// scaling again should replace the existing scale node
+	hook.Apply(pop[0], nil)
+	if len(pop[0].Code) != 4 {
+		t.Error("scale node should not be repeated", pop[0].Code)
+	}
+	if len(Unscaled(pop[1].Code)) != 1 || pop[1].Fitness >= 1 {
+		t.Error("constant should have a fit to the mean", pop[1])
+	}
+	// scaled subtree moved by crossover is also removed
+	nested := append(gp.Expr{Add, x}, pop[0].Code...)
+	if code := Unscaled(nested); len(code) != 5 || code.Format() != "(x + (x * x))" {
+		t.Error("nested scale node should be removed", code)
+	}
+	// negative coefficient
+	for i := range target {
+		target[i] = 3 - target[i]
+	}
+	hook.Apply(pop[0], nil)
+	if pop[0].Code.Format() != "(0 - 2 * (x * x))" {
+		t.Error("invalid format for negative scale", pop[0].Code.Format())
+	}
+	// save and reload scaled code
+	var buf bytes.Buffer
+	if err := pop.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(buf.String())
+	if _, err := gp.ReadPopulation(bytes.NewReader(buf.Bytes()), initPset(true)); err == nil {
+		t.Error("expecting error reading scale opcode without parser")
+	}
+	pset.Add(Mul, V(1))
+	pop2, err := gp.ReadPopulation(&buf, pset, ScaleParser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ind := range pop2 {
+		if ind.Code.Format() != pop[i].Code.Format() {
+			t.Errorf("got %s - expected %s", ind.Code.Format(), pop[i].Code.Format())
+		}
+	}
+}
+
+// test optimising ephemeral constants
+func TestOptimise(t *testing.T) {
+	pset := gp.CreatePrimSet(1, "x")
+	inputs, target := [][]gp.Value{}, []float64{}
+	for x := -1.0; x <= 1.0; x += 0.25 {
+		inputs = append(inputs, []gp.Value{V(x)})
+		target = append(target, 3+2*x)
+	}
+	model := &gp.Model{Fitness: func(code gp.Expr) (float64, bool) {
+		return 1 / (1 + SemanticDistance(Semantics(code, inputs), target)), true
+	}}
+	c := Ephemeral("ERC", func() V { return 1 })
+	x := pset.Var(0)
+	for _, lamarckian := range []bool{true, false} {
+		code := gp.Expr{Add, c.Init(), Mul, c.Init(), x}
+		pop := gp.Population{gp.Create(code)}
+		pop, _ = pop.Evaluate(model, 1, OptimiseConstants(200, lamarckian))
+		t.Log(pop[0])
+		if pop[0].Fitness < 0.99 {
+			t.Error("expected close fit after optimising constants")
+		}
+		if changed := pop[0].Code.Format() != code.Format(); changed != lamarckian {
+			t.Error("code should only be updated for lamarckian optimisation")
+		}
+	}
+}
+
+// test splitting a dataset
+func TestDataset(t *testing.T) {
+	gp.SetSeed(1)
+	data := NewDataset("x")
+	for i := 0; i < 100; i++ {
+		data.Add(float64(2*i), float64(i))
+	}
+	parts := data.Shuffle().Split(0.6, 0.2)
+	if len(parts) != 3 || parts[0].Len() != 60 || parts[1].Len() != 20 || parts[2].Len() != 20 {
+		t.Fatal("invalid split", parts)
+	}
+	if parts := data.Split(-0.1, 0.5); parts[0].Len() != 0 || parts[1].Len() != 50 || parts[2].Len() != 50 {
+		t.Error("negative fraction should give an empty part", parts)
+	}
+	seen := map[float64]bool{}
+	for _, part := range parts {
+		for i, in := range part.Inputs {
+			x := float64(in[0].(V))
+			if part.Target[i] != 2*x || seen[x] {
+				t.Error("invalid case", x, part.Target[i])
+			}
+			seen[x] = true
+		}
+	}
+	train, test := data.KFold(3)
+	for i := range train {
+		t.Log(train[i], test[i])
+		if train[i].Len()+test[i].Len() != 100 {
+			t.Error("invalid fold", i)
+		}
+	}
+	x := gp.CreatePrimSet(1, "x").Var(0)
+	if fit, _ := data.Fitness(gp.Expr{Add, x, x}); fit != 1 {
+		t.Error("expected exact fit", fit)
+	}
+}
+
+// test reading a dataset from CSV
+func TestReadCSV(t *testing.T) {
+	text := "a, y, b\n1, 10, 2\n# comment\n2, 20, NA\n3, , 4\n5, 50, 6\n"
+	d, err := ReadCSV(strings.NewReader(text), "y", ',', DropMissing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(d, d.Inputs, d.Target)
+	if d.Len() != 2 || d.Names[0] != "a" || d.Names[1] != "b" || d.Target[1] != 50 {
+		t.Error("invalid dataset")
+	}
+	d, err = ReadCSV(strings.NewReader(text), "y", ',', MeanMissing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(d, d.Inputs, d.Target)
+	if d.Len() != 3 || d.Inputs[1][1] != V(4) {
+		t.Error("expected missing value to be replaced by mean")
+	}
+	pset := d.PrimSet()
+	if pset.NumVars != 2 || pset.Var(1).String() != "b" {
+		t.Error("invalid primitive set", pset)
+	}
+	if _, err = ReadCSV(strings.NewReader("x\tz\n1\tfoo\n"), "", '\t', DropMissing); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
+
+// test math primitives
+func TestMath(t *testing.T) {
+	x := gp.CreatePrimSet(1, "x").Var(0)
+	tests := []struct {
+		code   gp.Expr
+		in     V
+		format string
+		result V
+	}{
+		{gp.Expr{Log, x}, 0, "log(x)", 0},
+		{gp.Expr{Log, x}, -math.E, "log(x)", 1},
+		{gp.Expr{Exp, x}, 1e6, "exp(x)", V(math.Exp(EXP_LIMIT))},
+		{gp.Expr{Sqrt, x}, -4, "sqrt(x)", 2},
+		{gp.Expr{Pow, x, V(-1)}, 0, "(x ^ -1)", 1},
+		{gp.Expr{Pow, x, V(3)}, 2, "(x ^ 3)", 8},
+		{gp.Expr{Square, Add, x, V(1)}, 2, "square((x + 1))", 9},
+		{gp.Expr{Square, Neg, V(1)}, 0, "square(-(1))", 1},
+		{gp.Expr{Cube, x}, -2, "cube(x)", -8},
+		{gp.Expr{Pow, V(-2), V(3)}, 0, "(-2 ^ 3)", 8},
+		{gp.Expr{Max, x, Abs, x}, -3, "max(x, abs(x))", 3},
+		{gp.Expr{Min, x, V(0)}, -3, "min(x, 0)", -3},
+		{gp.Expr{IfLTE, x, V(0), V(1), V(2)}, 0, "(x <= 0 ? 1 : 2)", 1},
+		{gp.Expr{Sigmoid, x}, 0, "sigmoid(x)", 0.5},
+		{gp.Expr{Tanh, Sin, Cos, Tan, x}, 0, "tanh(sin(cos(tan(x))))", V(math.Tanh(math.Sin(1)))},
+	}
+	for _, test := range tests {
+		val := test.code.Eval(test.in).(V)
+		format := test.code.Format()
+		t.Logf("%s = %g", format, val)
+		if format != test.format || math.Abs(float64(val-test.result)) > 1e-12 {
+			t.Errorf("expected %s = %g", test.format, test.result)
+		}
+	}
+}
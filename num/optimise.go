@@ -0,0 +1,143 @@
+package num
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"math"
+	"sort"
+)
+
+type optimiser struct {
+	maxEvals   int
+	lamarckian bool
+}
+
+// OptimiseConstants returns a hook which tunes the values of the ephemeral constants in each
+// individual to maximise the fitness using the Nelder-Mead simplex method, with up to maxEvals
+// calls to the fitness function per individual. If lamarckian is set then the tuned constants are
+// written back to the code, else only the fitness is updated (Baldwinian evaluation).
+func OptimiseConstants(maxEvals int, lamarckian bool) gp.Hook {
+	return optimiser{maxEvals, lamarckian}
+}
+
+func (h optimiser) String() string {
+	if h.lamarckian {
+		return fmt.Sprintf("OptimiseConstants(%d,lamarckian)", h.maxEvals)
+	}
+	return fmt.Sprintf("OptimiseConstants(%d,baldwinian)", h.maxEvals)
+}
+
+func (h optimiser) Apply(ind *gp.Individual, eval gp.Evaluator) {
+	pos := []int{}
+	x0 := []float64{}
+	for i, op := range ind.Code {
+		if e, ok := op.(erc); ok {
+			pos = append(pos, i)
+			x0 = append(x0, float64(e.V))
+		}
+	}
+	if len(pos) == 0 {
+		return
+	}
+	code := ind.Code.Clone()
+	setConsts := func(x []float64) {
+		for i, p := range pos {
+			e := code[p].(erc)
+			code[p] = erc{V(x[i]), e.gen, e.name}
+		}
+	}
+	valid := false
+	cost := func(x []float64) float64 {
+		setConsts(x)
+		fit, ok := eval.GetFitness(code)
+		if !ok || math.IsNaN(fit) {
+			return math.Inf(1)
+		}
+		valid = true
+		return -fit
+	}
+	best, fbest := nelderMead(cost, x0, h.maxEvals)
+	if !valid || math.IsInf(fbest, 0) {
+		return
+	}
+	if h.lamarckian {
+		setConsts(best)
+		ind.Code = code
+	}
+	ind.Fitness, ind.FitnessValid = -fbest, true
+}
+
+// minimise f using the Nelder-Mead downhill simplex method starting from x0
+func nelderMead(f func([]float64) float64, x0 []float64, maxEvals int) ([]float64, float64) {
+	n := len(x0)
+	type point struct {
+		x []float64
+		f float64
+	}
+	simplex := make([]point, n+1)
+	simplex[0] = point{append([]float64{}, x0...), f(x0)}
+	for i := 0; i < n; i++ {
+		x := append([]float64{}, x0...)
+		if x[i] != 0 {
+			x[i] *= 1.1
+		} else {
+			x[i] = 0.5
+		}
+		simplex[i+1] = point{x, f(x)}
+	}
+	// returns c + t*(x - c)
+	along := func(c, x []float64, t float64) []float64 {
+		y := make([]float64, n)
+		for i := range y {
+			y[i] = c[i] + t*(x[i]-c[i])
+		}
+		return y
+	}
+	for evals := n + 1; evals < maxEvals; {
+		sort.Slice(simplex, func(i, j int) bool { return simplex[i].f < simplex[j].f })
+		centroid := make([]float64, n)
+		for _, p := range simplex[:n] {
+			for i := range centroid {
+				centroid[i] += p.x[i] / float64(n)
+			}
+		}
+		worst := simplex[n]
+		xr := along(centroid, worst.x, -1)
+		fr := f(xr)
+		evals++
+		switch {
+		case fr < simplex[0].f:
+			xe := along(centroid, worst.x, -2)
+			fe := f(xe)
+			evals++
+			if fe < fr {
+				simplex[n] = point{xe, fe}
+			} else {
+				simplex[n] = point{xr, fr}
+			}
+		case fr < simplex[n-1].f:
+			simplex[n] = point{xr, fr}
+		default:
+			xc := along(centroid, worst.x, 0.5)
+			fc := f(xc)
+			evals++
+			if fc < worst.f {
+				simplex[n] = point{xc, fc}
+			} else {
+				// shrink towards the best point
+				for i := 1; i <= n; i++ {
+					x := along(simplex[0].x, simplex[i].x, 0.5)
+					simplex[i] = point{x, f(x)}
+					evals++
+				}
+			}
+		}
+	}
+	best := simplex[0]
+	for _, p := range simplex[1:] {
+		if p.f < best.f {
+			best = p
+		}
+	}
+	return best.x, best.f
+}
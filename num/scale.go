@@ -0,0 +1,111 @@
+package num
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"strconv"
+	"strings"
+)
+
+// scale opcode is added at the root of the tree by the LinearScaling hook
+type scale struct {
+	a, b V
+}
+
+func (o scale) Arity() int { return 1 }
+
+func (o scale) Eval(args ...gp.Value) gp.Value { return o.a + o.b*args[0].(V) }
+
+func (o scale) String() string { return fmt.Sprintf("scale(%g,%g)", float64(o.a), float64(o.b)) }
+
+func (o scale) Format(args ...string) string {
+	if o.b < 0 {
+		return fmt.Sprintf("(%g - %g * %s)", float64(o.a), -float64(o.b), args[0])
+	}
+	return fmt.Sprintf("(%g + %g * %s)", float64(o.a), float64(o.b), args[0])
+}
+
+// Parse returns a scale opcode with the coefficients given by text in the format returned by
+// the String method.
+func (o scale) Parse(text string) (gp.Opcode, error) {
+	if !strings.HasPrefix(text, "scale(") || !strings.HasSuffix(text, ")") {
+		return nil, fmt.Errorf("invalid scale opcode %q", text)
+	}
+	coeffs := strings.Split(text[6:len(text)-1], ",")
+	if len(coeffs) != 2 {
+		return nil, fmt.Errorf("invalid scale opcode %q", text)
+	}
+	a, err := strconv.ParseFloat(coeffs[0], 64)
+	if err != nil {
+		return nil, err
+	}
+	b, err := strconv.ParseFloat(coeffs[1], 64)
+	if err != nil {
+		return nil, err
+	}
+	return scale{V(a), V(b)}, nil
+}
+
+// ScaleParser converts the scale opcodes added by the LinearScaling hook back from text. It
+// should be passed to gp.ReadPopulation when loading a population which has been scaled.
+var ScaleParser gp.ConstantParser = scale{}
+
+type linearScaling struct {
+	inputs [][]gp.Value
+	target []float64
+}
+
+// LinearScaling returns a hook which calculates the optimal a + b*f(x) to fit the output of each
+// individual to the target values for the given inputs, using least squares. The code is replaced
+// with a scaled version before the fitness is evaluated so it is reported by Format and Best.
+func LinearScaling(inputs [][]gp.Value, target []float64) gp.Hook {
+	return linearScaling{inputs, target}
+}
+
+func (h linearScaling) String() string { return "LinearScaling" }
+
+func (h linearScaling) Apply(ind *gp.Individual, eval gp.Evaluator) {
+	code := Unscaled(ind.Code)
+	a, b := LinearFit(Semantics(code, h.inputs), h.target)
+	ind.Code = append(gp.Expr{scale{V(a), V(b)}}, code...)
+}
+
+// Unscaled returns the code with any scaling added by the LinearScaling hook removed. This
+// includes scale nodes which have been moved into a subtree by crossover or mutation.
+func Unscaled(code gp.Expr) gp.Expr {
+	out := make(gp.Expr, 0, len(code))
+	for _, op := range code {
+		if _, ok := op.(scale); !ok {
+			out = append(out, op)
+		}
+	}
+	if len(out) == len(code) {
+		return code
+	}
+	return out
+}
+
+// LinearFit returns the coefficients a and b such that a + b*x[i] is the least squares fit to y[i].
+// If x is constant then b is zero.
+func LinearFit(x, y []float64) (a, b float64) {
+	n := float64(len(x))
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	avgX, avgY := sumX/n, sumY/n
+	var cov, vari float64
+	for i := range x {
+		cov += (x[i] - avgX) * (y[i] - avgY)
+		vari += (x[i] - avgX) * (x[i] - avgX)
+	}
+	if vari > 1e-12 && vari < 1e300 && cov == cov {
+		b = cov / vari
+	}
+	a = avgY - b*avgX
+	if b == 0 {
+		a = avgY
+	}
+	return
+}
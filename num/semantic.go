@@ -0,0 +1,224 @@
+package num
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"math"
+	"math/rand"
+	"sync/atomic"
+)
+
+// Semantics returns the output of the code for each of the fitness cases in inputs.
+// The results for geometric semantic individuals are cached for the inputs passed to the
+// GeometricCx or GeometricMut variation which created them.
+func Semantics(code gp.Expr, inputs [][]gp.Value) []float64 {
+	if len(code) == 1 {
+		if g, ok := code[0].(*geometric); ok {
+			return g.semantics(inputs)
+		}
+	}
+	out := make([]float64, len(inputs))
+	for i, in := range inputs {
+		out[i] = float64(code.Eval(in...).(V))
+	}
+	return out
+}
+
+// SemanticDistance returns the mean absolute difference between two semantic vectors.
+func SemanticDistance(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += math.Abs(a[i] - b[i])
+	}
+	if math.IsNaN(sum) {
+		return math.Inf(1)
+	}
+	return sum / float64(len(a))
+}
+
+// SemanticCx returns a semantic similarity based crossover Variation. Random subtrees are
+// chosen from each parent until the semantic distance between them is between lower and upper.
+// If no match is found after the given number of trials then the last pair chosen is swapped.
+func SemanticCx(inputs [][]gp.Value, lower, upper float64, trials int) gp.Variation {
+	cross := func(ind gp.Population) gp.Population {
+		if ind[0].Size() < 2 || ind[1].Size() < 2 {
+			return ind
+		}
+		var pos1, pos2 int
+		var subtree1, subtree2 gp.Expr
+		for i := 0; i < trials; i++ {
+			pos1, subtree1 = ind[0].Code.RandomSubtree()
+			pos2, subtree2 = ind[1].Code.RandomSubtree()
+			dist := SemanticDistance(Semantics(subtree1, inputs), Semantics(subtree2, inputs))
+			if dist >= lower && dist <= upper {
+				break
+			}
+		}
+		ind[0] = gp.Create(ind[0].Code.ReplaceSubtree(pos1, subtree2))
+		ind[1] = gp.Create(ind[1].Code.ReplaceSubtree(pos2, subtree1))
+		return ind
+	}
+	return gp.NewVariation(fmt.Sprintf("SemanticCx(%g,%g)", lower, upper), cross)
+}
+
+// SemanticMut returns a semantic aware mutation Variation. A random subtree is replaced by a new
+// tree from gen. If the child is semantically equivalent to the parent, i.e. the distance is less
+// than epsilon, then it is rejected and a new mutation is tried, up to the given number of trials.
+func SemanticMut(inputs [][]gp.Value, gen gp.Generator, epsilon float64, trials int) gp.Variation {
+	mutate := func(ind gp.Population) gp.Population {
+		parent := Semantics(ind[0].Code, inputs)
+		for i := 0; i < trials; i++ {
+			pos := rand.Intn(len(ind[0].Code))
+			tree := ind[0].Code.Clone().ReplaceSubtree(pos, gen.Generate().Code)
+			if SemanticDistance(parent, Semantics(tree, inputs)) >= epsilon {
+				ind[0] = gp.Create(tree)
+				break
+			}
+		}
+		return ind
+	}
+	return gp.NewVariation(fmt.Sprintf("SemanticMut(%s)", gen), mutate)
+}
+
+// geometric semantic individual - a single opcode which combines the outputs of its parts.
+// Parts may themselves be geometric so are shared rather than copied, and the output for the
+// variation inputs is cached so evaluation time does not grow with each generation.
+type geometric struct {
+	id      uint64
+	size    float64
+	depth   int
+	parts   []gp.Expr
+	combine func(vals []float64) float64
+	inputs  [][]gp.Value
+	out     []float64
+}
+
+var geometricId uint64
+
+func newGeometric(inputs [][]gp.Value, combine func([]float64) float64, parts ...gp.Expr) gp.Expr {
+	g := &geometric{
+		id:      atomic.AddUint64(&geometricId, 1),
+		size:    1,
+		parts:   parts,
+		combine: combine,
+		inputs:  inputs,
+	}
+	for _, part := range parts {
+		size, depth := expandedSize(part)
+		g.size += size
+		if depth+1 > g.depth {
+			g.depth = depth + 1
+		}
+	}
+	sems := make([][]float64, len(parts))
+	for i, part := range parts {
+		sems[i] = Semantics(part, inputs)
+	}
+	g.out = make([]float64, len(inputs))
+	vals := make([]float64, len(parts))
+	for i := range inputs {
+		for j := range parts {
+			vals[j] = sems[j][i]
+		}
+		g.out[i] = combine(vals)
+	}
+	return gp.Expr{g}
+}
+
+// size and depth of the equivalent tree
+func expandedSize(code gp.Expr) (float64, int) {
+	if len(code) == 1 {
+		if g, ok := code[0].(*geometric); ok {
+			return g.size, g.depth
+		}
+	}
+	return float64(len(code)), code.Depth()
+}
+
+// semantics returns the cached output if inputs are the ones the individual was created with,
+// else evaluates it for each of the inputs without caching.
+func (g *geometric) semantics(inputs [][]gp.Value) []float64 {
+	if len(inputs) == len(g.inputs) && (len(inputs) == 0 || &inputs[0] == &g.inputs[0]) {
+		return g.out
+	}
+	out := make([]float64, len(inputs))
+	for i, in := range inputs {
+		out[i] = g.eval(in, map[*geometric]float64{})
+	}
+	return out
+}
+
+func (g *geometric) Arity() int { return 0 }
+
+func (g *geometric) String() string { return fmt.Sprintf("GS%d", g.id) }
+
+// Format returns the id and the size of the equivalent tree, which may be very large.
+func (g *geometric) Format(args ...string) string {
+	return fmt.Sprintf("GS%d[size=%.4g]", g.id, g.size)
+}
+
+// Size returns the size of the equivalent tree, limited to the maximum int value.
+func (g *geometric) Size() int {
+	if g.size >= math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(g.size)
+}
+
+// Depth returns the depth of the equivalent tree.
+func (g *geometric) Depth() int {
+	return g.depth
+}
+
+// Eval evaluates each of the parts and combines them. Shared parts are only evaluated once.
+func (g *geometric) Eval(input ...gp.Value) gp.Value {
+	return V(g.eval(input, map[*geometric]float64{}))
+}
+
+func (g *geometric) eval(input []gp.Value, done map[*geometric]float64) float64 {
+	if val, ok := done[g]; ok {
+		return val
+	}
+	vals := make([]float64, len(g.parts))
+	for i, part := range g.parts {
+		if p, ok := part[0].(*geometric); ok && len(part) == 1 {
+			vals[i] = p.eval(input, done)
+		} else {
+			vals[i] = float64(part.Eval(input...).(V))
+		}
+	}
+	done[g] = g.combine(vals)
+	return done[g]
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// GeometricCx returns Moraglio's geometric semantic crossover. The child is r*p1 + (1-r)*p2
+// where r is a random tree generated by gen, bounded to the range 0 to 1 with a logistic function.
+// Children are represented by a single opcode which references the parents, with outputs for
+// the fitness cases in inputs being cached, to avoid exponential growth in the size of the code.
+func GeometricCx(inputs [][]gp.Value, gen gp.Generator) gp.Variation {
+	cross := func(ind gp.Population) gp.Population {
+		r := gen.Generate().Code
+		combine1 := func(v []float64) float64 { s := sigmoid(v[2]); return s*v[0] + (1-s)*v[1] }
+		combine2 := func(v []float64) float64 { s := sigmoid(v[2]); return s*v[1] + (1-s)*v[0] }
+		p1, p2 := ind[0].Code, ind[1].Code
+		ind[0] = gp.Create(newGeometric(inputs, combine1, p1, p2, r))
+		ind[1] = gp.Create(newGeometric(inputs, combine2, p1, p2, r))
+		return ind
+	}
+	return gp.NewVariation(fmt.Sprintf("GeometricCx(%s)", gen), cross)
+}
+
+// GeometricMut returns Moraglio's geometric semantic mutation. The child is p + step*(r1 - r2)
+// where r1 and r2 are random trees from gen bounded to the range 0 to 1 with a logistic function.
+func GeometricMut(inputs [][]gp.Value, gen gp.Generator, step float64) gp.Variation {
+	mutate := func(ind gp.Population) gp.Population {
+		combine := func(v []float64) float64 { return v[0] + step*(sigmoid(v[1])-sigmoid(v[2])) }
+		ind[0] = gp.Create(newGeometric(inputs, combine, ind[0].Code, gen.Generate().Code, gen.Generate().Code))
+		return ind
+	}
+	return gp.NewVariation(fmt.Sprintf("GeometricMut(%s,%g)", gen, step), mutate)
+}
@@ -0,0 +1,30 @@
+//go:build gtk
+// +build gtk
+
+package stats
+
+import (
+	"github.com/mattn/go-gtk/gtk"
+	"github.com/mattn/go-webkit/webkit"
+)
+
+// HasBrowser is set if the package was built with support for the embedded web browser.
+const HasBrowser = true
+
+// StartBrowser launches a web browser with given url and size
+func StartBrowser(url string, width, height int) {
+	gtk.Init(nil)
+	window := gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	window.SetTitle("gogp")
+	window.Connect("destroy", gtk.MainQuit)
+	swin := gtk.NewScrolledWindow(nil, nil)
+	swin.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	swin.SetShadowType(gtk.SHADOW_IN)
+	webview := webkit.NewWebView()
+	swin.Add(webview)
+	window.Add(swin)
+	window.SetSizeRequest(width, height)
+	webview.LoadUri(url)
+	window.ShowAll()
+	gtk.Main()
+}
@@ -0,0 +1,283 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+)
+
+// Checkpoint files are written with this name, formatted with the generation number.
+var CheckpointFile = "checkpoint_%03d.pop"
+
+// Params holds the run parameters which can be changed between generations via the control API.
+// When updating, fields which are nil are left unchanged.
+type Params struct {
+	MutateProb, CrossoverProb *float64 `json:",omitempty"`
+	TournamentSize            *int     `json:",omitempty"`
+	TargetFitness             *float64 `json:",omitempty"`
+	MaxGen                    *int     `json:",omitempty"`
+}
+
+// The Status struct holds the current parameters and state of a controlled run.
+type Status struct {
+	Params
+	Gen             int
+	Paused, Stopped bool
+}
+
+// control state for a run
+type control struct {
+	model      *gp.Model
+	pending    []Params
+	status     Status
+	resume     chan empty
+	stop       bool
+	checkpoint bool
+}
+
+// Control enables the control API for the given model. Requests to pause, resume, stop,
+// checkpoint or change parameters are applied by the Logger between generations.
+func (l *Logger) Control(m *gp.Model) {
+	l.Lock()
+	defer l.Unlock()
+	l.ctrl = &control{model: m}
+	l.ctrl.status = Status{Params: l.getParams(), Gen: -1}
+}
+
+// get current parameters, must be called with lock held from the thread running the model
+func (l *Logger) getParams() Params {
+	m := l.ctrl.model
+	p := Params{
+		MutateProb:    new(float64),
+		CrossoverProb: new(float64),
+		TargetFitness: new(float64),
+		MaxGen:        new(int),
+	}
+	*p.MutateProb, *p.CrossoverProb = m.MutateProb, m.CrossoverProb
+	*p.TargetFitness, *p.MaxGen = l.TargetFitness, l.MaxGen
+	if sel := reflect.Indirect(reflect.ValueOf(m.Offspring)); sel.Kind() == reflect.Struct {
+		if fld := sel.FieldByName("TournamentSize"); fld.IsValid() && fld.Kind() == reflect.Int {
+			p.TournamentSize = new(int)
+			*p.TournamentSize = int(fld.Int())
+		}
+	}
+	return p
+}
+
+// Pause suspends the run at the end of the current generation.
+func (l *Logger) Pause() {
+	l.Lock()
+	defer l.Unlock()
+	if l.ctrl != nil && l.ctrl.resume == nil {
+		l.ctrl.resume = make(chan empty)
+		l.ctrl.status.Paused = true
+	}
+}
+
+// Resume continues a paused run.
+func (l *Logger) Resume() {
+	l.Lock()
+	defer l.Unlock()
+	if l.ctrl != nil {
+		l.ctrl.wake()
+	}
+}
+
+// Stop ends the run gracefully at the end of the current generation.
+func (l *Logger) Stop() {
+	l.Lock()
+	defer l.Unlock()
+	if l.ctrl != nil {
+		l.ctrl.stop = true
+		l.ctrl.wake()
+	}
+}
+
+// restart run if paused
+func (c *control) wake() {
+	if c.resume != nil {
+		close(c.resume)
+		c.resume = nil
+		c.status.Paused = false
+	}
+}
+
+// Checkpoint saves the population to CheckpointFile at the end of the current generation.
+func (l *Logger) Checkpoint() {
+	l.Lock()
+	defer l.Unlock()
+	if l.ctrl != nil {
+		l.ctrl.checkpoint = true
+	}
+}
+
+// SetParams queues a change to the run parameters which will apply from the end of the current
+// generation. Returns an error if the tournament size is given and the selector does not have one.
+func (l *Logger) SetParams(p Params) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.ctrl == nil {
+		return nil
+	}
+	if p.TournamentSize != nil {
+		if _, err := setTournamentSize(l.ctrl.model.Offspring, *p.TournamentSize); err != nil {
+			return err
+		}
+	}
+	l.ctrl.pending = append(l.ctrl.pending, p)
+	return nil
+}
+
+// returns a copy of the selector with the TournamentSize field updated
+func setTournamentSize(sel gp.Selector, size int) (gp.Selector, error) {
+	val := reflect.ValueOf(sel)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	} else if val.Kind() == reflect.Struct {
+		dup := reflect.New(val.Type()).Elem()
+		dup.Set(val)
+		val = dup
+	}
+	if val.Kind() == reflect.Struct {
+		if fld := val.FieldByName("TournamentSize"); fld.IsValid() && fld.Kind() == reflect.Int && fld.CanSet() {
+			fld.SetInt(int64(size))
+			if reflect.TypeOf(sel).Kind() == reflect.Ptr {
+				return sel, nil
+			}
+			return val.Interface().(gp.Selector), nil
+		}
+	}
+	return sel, fmt.Errorf("selector %s does not have a tournament size", sel)
+}
+
+// Status returns the current parameters and state of the run.
+func (l *Logger) Status() Status {
+	l.Lock()
+	defer l.Unlock()
+	if l.ctrl == nil {
+		return Status{}
+	}
+	return l.ctrl.status
+}
+
+// apply queued control requests at the end of a generation before the stats are published.
+// Changes are added to the notes for the latest generation. Returns true if the run should be
+// stopped and a channel to wait on before continuing if it has been paused.
+func (l *Logger) applyControl(pop gp.Population, s *Stats) (stop bool, resume chan empty) {
+	l.Lock()
+	defer l.Unlock()
+	c := l.ctrl
+	if c == nil {
+		return false, nil
+	}
+	note := func(name string, value interface{}) {
+		s.Notes = append(s.Notes, gp.FormatParam(name, value))
+	}
+	for _, p := range c.pending {
+		if p.MutateProb != nil {
+			c.model.MutateProb = *p.MutateProb
+			note("MutateProb", c.model.MutateProb)
+		}
+		if p.CrossoverProb != nil {
+			c.model.CrossoverProb = *p.CrossoverProb
+			note("CrossoverProb", c.model.CrossoverProb)
+		}
+		if p.TournamentSize != nil {
+			if sel, err := setTournamentSize(c.model.Offspring, *p.TournamentSize); err == nil {
+				c.model.Offspring = sel
+				note("Offspring", c.model.Offspring)
+			}
+		}
+		if p.TargetFitness != nil {
+			l.TargetFitness = *p.TargetFitness
+			note("TargetFitness", l.TargetFitness)
+		}
+		if p.MaxGen != nil {
+			l.MaxGen = *p.MaxGen
+			note("MaxGen", l.MaxGen)
+		}
+	}
+	c.pending = nil
+	if c.checkpoint {
+		c.checkpoint = false
+		note("Checkpoint", l.saveCheckpoint(pop, s.Gen))
+	}
+	stop = c.stop
+	if stop {
+		c.stop = false
+		note("Stopped", s.Gen)
+	}
+	c.status.Params = l.getParams()
+	c.status.Gen = s.Gen
+	c.status.Stopped = stop
+	resume = c.resume
+	if resume != nil {
+		note("Paused", s.Gen)
+	}
+	return stop, resume
+}
+
+// write population to checkpoint file, returns file name or error message
+func (l *Logger) saveCheckpoint(pop gp.Population, gen int) string {
+	file := fmt.Sprintf(CheckpointFile, gen)
+	f, err := os.Create(file)
+	if err == nil {
+		err = pop.Write(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		os.Remove(file)
+		log.Println("error writing checkpoint:", err)
+		return err.Error()
+	}
+	return file
+}
+
+// return handler for control API requests. URL is of form /control/<action>
+//
+//	GET  /control/status      current parameters and state
+//	POST /control/params      update parameters from JSON encoded Params struct
+//	POST /control/pause       pause the run
+//	POST /control/resume      resume a paused run
+//	POST /control/stop        stop the current run
+//	POST /control/checkpoint  save the population to a checkpoint file
+func (l *Logger) controlHandler(patternLen int) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		action := r.URL.Path[patternLen:]
+		if action != "status" && r.Method != "POST" {
+			http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+			return
+		}
+		switch action {
+		case "status":
+		case "params":
+			var p Params
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := l.SetParams(p); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case "pause":
+			l.Pause()
+		case "resume":
+			l.Resume()
+		case "stop":
+			l.Stop()
+		case "checkpoint":
+			l.Checkpoint()
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		sendJSON(w, r, l.Status())
+	}
+}
@@ -0,0 +1,98 @@
+package stats
+
+import (
+	"github.com/jnb666/gogp/gp"
+	"math"
+	"math/rand"
+)
+
+// Number of pairs of individuals which are sampled to calculate the distance between them.
+var SamplePairs = 100
+
+// calculate the structural and fitness diversity measures for the population.
+// Uses a separate random number source so as not to change the sequence for the run.
+func (s *Stats) diversity(pop gp.Population) {
+	unique := map[uint64]bool{}
+	for _, ind := range pop {
+		unique[ind.Code.Hash()] = true
+	}
+	s.Unique = float64(len(unique)) / float64(len(pop))
+	s.Entropy = entropy(s.FitHist)
+	subtrees := map[int]map[uint64]int{}
+	getSubtrees := func(i int) map[uint64]int {
+		if _, ok := subtrees[i]; !ok {
+			subtrees[i] = map[uint64]int{}
+			for _, h := range pop[i].Code.Hashes() {
+				subtrees[i][h]++
+			}
+		}
+		return subtrees[i]
+	}
+	rng := rand.New(rand.NewSource(int64(s.Gen) + 1))
+	dist := make([]float64, SamplePairs)
+	for i := range dist {
+		a, b := rng.Intn(len(pop)), rng.Intn(len(pop))
+		dist[i] = subtreeDistance(getSubtrees(a), getSubtrees(b))
+	}
+	s.Distance = summarise(dist)
+}
+
+// Entropy of fitness histogram in bits.
+func entropy(hist []int) float64 {
+	total := 0
+	for _, n := range hist {
+		total += n
+	}
+	h := 0.0
+	for _, n := range hist {
+		if n > 0 {
+			p := float64(n) / float64(total)
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// Subtree overlap distance between two trees, 0 if identical, 1 if no subtrees are in common.
+func subtreeDistance(a, b map[uint64]int) float64 {
+	common, total := 0, 0
+	for h, na := range a {
+		if nb, ok := b[h]; ok {
+			common += int(math.Min(float64(na), float64(nb)))
+		}
+		total += na
+	}
+	for _, nb := range b {
+		total += nb
+	}
+	return 1 - 2*float64(common)/float64(total)
+}
+
+// Phenotypic calculates the distance between the outputs of a sample of pairs of individuals from
+// the population and stores the results in the Pheno field. The outputs function should return
+// the output of the code for each of the test cases. Distance is the root mean squared difference.
+func (s *Stats) Phenotypic(pop gp.Population, outputs func(gp.Expr) []float64) {
+	cache := map[int][]float64{}
+	getOutputs := func(i int) []float64 {
+		if _, ok := cache[i]; !ok {
+			cache[i] = outputs(pop[i].Code)
+		}
+		return cache[i]
+	}
+	rng := rand.New(rand.NewSource(int64(s.Gen) + 1))
+	dist := make([]float64, 0, SamplePairs)
+	for i := 0; i < SamplePairs; i++ {
+		a, b := getOutputs(rng.Intn(len(pop))), getOutputs(rng.Intn(len(pop)))
+		sum, n := 0.0, 0
+		for j := 0; j < len(a) && j < len(b); j++ {
+			if d := a[j] - b[j]; !math.IsNaN(d) && !math.IsInf(d, 0) {
+				sum += d * d
+				n++
+			}
+		}
+		if n > 0 {
+			dist = append(dist, math.Sqrt(sum/float64(n)))
+		}
+	}
+	s.Pheno = summarise(dist)
+}
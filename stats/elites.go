@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ajstarks/svgo"
+	"github.com/jnb666/gogp/gp"
+)
+
+// ElitesPlot returns a function which draws a heatmap of the MAP-Elites archive as an SVG image
+// of the given width and height which can be registered with Logger.RegisterSVGPlot. The first
+// dimension is along the x axis and the second along the y axis. If there are more than two
+// dimensions then the best fitness over the other dimensions is shown. Each cell is coloured
+// from blue for low fitness to red for high fitness, and empty cells are grey.
+func ElitesPlot(e *gp.Elites, width, height int) func(gp.Population) []byte {
+	const margin = 30
+	return func(pop gp.Population) []byte {
+		cols, rows := 1, 1
+		if len(e.Dims) > 0 {
+			cols = e.Dims[0].Bins
+		}
+		if len(e.Dims) > 1 {
+			rows = e.Dims[1].Bins
+		}
+		best := make([]*gp.Individual, cols*rows)
+		for _, ind := range e.Population() {
+			bins := e.Cell(ind)
+			x, y := 0, 0
+			if len(bins) > 0 {
+				x = bins[0]
+			}
+			if len(bins) > 1 {
+				y = bins[1]
+			}
+			if i := x + y*cols; best[i] == nil || ind.Fitness > best[i].Fitness {
+				best[i] = ind
+			}
+		}
+		var buf bytes.Buffer
+		plot := svg.New(&buf)
+		plot.Start(width, height)
+		cw, ch := (width-margin)/cols, (height-margin)/rows
+		for y := 0; y < rows; y++ {
+			for x := 0; x < cols; x++ {
+				style := "fill:#e0e0e0"
+				title := "empty"
+				if ind := best[x+y*cols]; ind != nil {
+					style = fmt.Sprintf("fill:hsl(%d,80%%,50%%)", int(240*(1-clamp(ind.Fitness))))
+					title = fmt.Sprintf("fitness %.3g: %s", ind.Fitness, ind.Code.Format())
+				}
+				plot.Group()
+				plot.Title(title)
+				plot.Rect(margin+x*cw, (rows-1-y)*ch, cw-1, ch-1, style)
+				plot.Gend()
+			}
+		}
+		if len(e.Dims) > 0 {
+			d := e.Dims[0]
+			plot.Text(margin, height-margin/3, fmt.Sprint(d.Min), "font-size:10px")
+			plot.Text(width/2, height-margin/3, d.Name, "font-size:12px;text-anchor:middle")
+			plot.Text(width, height-margin/3, fmt.Sprint(d.Max), "font-size:10px;text-anchor:end")
+		}
+		if len(e.Dims) > 1 {
+			d := e.Dims[1]
+			plot.Text(margin-4, height-margin, fmt.Sprint(d.Min), "font-size:10px;text-anchor:end")
+			plot.Text(margin/2, (height-margin)/2, d.Name,
+				"font-size:12px;text-anchor:middle;writing-mode:tb")
+			plot.Text(margin-4, 10, fmt.Sprint(d.Max), "font-size:10px;text-anchor:end")
+		}
+		plot.End()
+		return buf.Bytes()
+	}
+}
+
+func clamp(fit float64) float64 {
+	if fit < 0 {
+		return 0
+	}
+	if fit > 1 {
+		return 1
+	}
+	return fit
+}
@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Size of the buffered channel for each client. If a client falls further behind than this then
+// events are dropped - it can catch up by requesting the missing generations from /stats/<gen>.
+var EventBuffer = 64
+
+// The Event struct holds the data for a single generation which is pushed to web clients
+// as a server-sent event via /events.
+type Event struct {
+	Done        bool
+	Gen, MaxGen int
+	Stats       []string
+	Best        string
+	Plot        []Plot `json:",omitempty"`
+}
+
+// add a new client to receive events
+func (l *Logger) subscribe() chan []byte {
+	l.Lock()
+	defer l.Unlock()
+	if l.clients == nil {
+		l.clients = map[chan []byte]bool{}
+	}
+	ch := make(chan []byte, EventBuffer)
+	l.clients[ch] = true
+	return ch
+}
+
+// remove client - called when connection is closed
+func (l *Logger) unsubscribe(ch chan []byte) {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.clients, ch)
+}
+
+// send event to each of the clients without blocking, must be called with lock held
+func (l *Logger) publish(name string, data []byte) {
+	msg := []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", name, data))
+	for ch := range l.clients {
+		select {
+		case ch <- msg:
+		default:
+			if Debug {
+				log.Println("client not keeping up - dropped", name, "event")
+			}
+		}
+	}
+}
+
+// publish stats for latest generation, must be called with lock held
+func (l *Logger) publishStats(s *Stats) {
+	if len(l.clients) == 0 {
+		return
+	}
+	ev := Event{
+		Done:   l.done,
+		Gen:    s.Gen,
+		MaxGen: l.MaxGen,
+		Stats:  s.LogValues(),
+		Best:   s.Best.Code.Format(),
+		Plot:   l.plots,
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Println("error encoding event:", err)
+		return
+	}
+	l.publish("stats", data)
+}
+
+// handler to stream events to the client until the connection is closed
+func (l *Logger) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	ch := l.subscribe()
+	defer l.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	for {
+		select {
+		case msg := <-ch:
+			w.Write(msg)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
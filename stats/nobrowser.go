@@ -0,0 +1,17 @@
+//go:build !gtk
+// +build !gtk
+
+package stats
+
+import (
+	"log"
+)
+
+// HasBrowser is set if the package was built with support for the embedded web browser.
+// Build with the gtk tag to enable this - it requires the go-gtk and go-webkit packages.
+const HasBrowser = false
+
+// StartBrowser is a placeholder if built without the gtk tag. It just logs the url to connect to.
+func StartBrowser(url string, width, height int) {
+	log.Println("browser support not built - connect to", url)
+}
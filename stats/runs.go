@@ -0,0 +1,238 @@
+package stats
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Colours used for each run when plotting a comparison.
+var RunColors = []string{"#ff0000", "#0000ff", "#00a000", "#ff8000", "#a000a0",
+	"#00a0a0", "#804000", "#808080", "#ff60a0", "#000000"}
+
+// DefaultRegistry is used to record the runs for each Logger created with NewLogger.
+var DefaultRegistry = &Registry{MaxRuns: 10}
+
+// A Run holds the history of stats for a single run of a model, which may be live or completed.
+type Run struct {
+	Id      int
+	Name    string
+	Started time.Time
+	Done    bool
+	history []*Stats
+	pruned  bool
+	last    RunInfo
+}
+
+// The RunInfo struct holds a summary of a run which is served via HTTP in JSON format
+type RunInfo struct {
+	Id      int
+	Name    string
+	Started time.Time
+	Done    bool
+	Gen     int
+	FitMax  float64
+	Best    string
+}
+
+// A Registry keeps the history for a list of live and completed runs so that they can be compared.
+// Runs may be from different models or seeds - if multiple loggers share a registry then
+// they should each be given a Name to identify them. If MaxRuns is non zero then only the
+// history for this number of runs is kept, and older completed runs are reduced to a summary.
+type Registry struct {
+	sync.Mutex
+	MaxRuns int
+	runs    []*Run
+}
+
+// add a new live run
+func (r *Registry) start(name string) *Run {
+	r.Lock()
+	defer r.Unlock()
+	if name == "" {
+		name = "run"
+	}
+	run := &Run{Id: len(r.runs), Name: name, Started: time.Now()}
+	r.runs = append(r.runs, run)
+	r.prune()
+	return run
+}
+
+// discard the history for the oldest completed runs if there are more than MaxRuns
+func (r *Registry) prune() {
+	if r.MaxRuns <= 0 {
+		return
+	}
+	kept := 0
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		run := r.runs[i]
+		if run.pruned {
+			continue
+		}
+		if kept++; kept > r.MaxRuns && run.Done {
+			run.last = run.info()
+			run.history, run.pruned = nil, true
+		}
+	}
+}
+
+// summary of the run
+func (run *Run) info() RunInfo {
+	if run.pruned {
+		return run.last
+	}
+	info := RunInfo{Id: run.Id, Name: run.Name, Started: run.Started, Done: run.Done, Gen: -1}
+	if last := len(run.history) - 1; last >= 0 {
+		info.Gen = last
+		info.FitMax = run.history[last].Fit.Max
+		info.Best = run.history[last].Best.Code.Format()
+	}
+	return info
+}
+
+// append stats for latest generation to the run
+func (r *Registry) update(run *Run, s *Stats, done bool) {
+	r.Lock()
+	defer r.Unlock()
+	run.history = append(run.history, s)
+	run.Done = done
+}
+
+// flag that run has finished
+func (r *Registry) finish(run *Run) {
+	r.Lock()
+	defer r.Unlock()
+	run.Done = true
+}
+
+// List returns a summary of each of the runs in the registry.
+func (r *Registry) List() []RunInfo {
+	r.Lock()
+	defer r.Unlock()
+	list := make([]RunInfo, len(r.runs))
+	for i, run := range r.runs {
+		list[i] = run.info()
+	}
+	return list
+}
+
+// History returns a copy of the list of stats for the run with given id.
+func (r *Registry) History(id int) (h []*Stats, err error) {
+	r.Lock()
+	defer r.Unlock()
+	if id < 0 || id >= len(r.runs) {
+		err = fmt.Errorf("run %d not found", id)
+		return
+	}
+	if r.runs[id].pruned {
+		err = fmt.Errorf("history for run %d has been discarded", id)
+		return
+	}
+	h = append([]*Stats{}, r.runs[id].history...)
+	return
+}
+
+// get plot stats for given run
+func (r *Registry) getPlotStats(id int) (data PlotStats, err error) {
+	h, err := r.History(id)
+	if err != nil {
+		return
+	}
+	r.Lock()
+	data.Done = r.runs[id].Done
+	r.Unlock()
+	data.Headers = LogHeaders()
+	data.Stats = [][]string{}
+	for _, s := range h {
+		data.Stats = append(data.Stats, s.LogValues())
+	}
+	data.Gen = len(h) - 1
+	if data.Gen >= 0 {
+		data.Best = h[data.Gen].Best.Code.Format()
+	}
+	return
+}
+
+// Compare returns a plot with a line for each of the given runs for the named stats field.
+// e.g. Compare("Fit.Max", 0, 1) compares the maximum fitness for the first two runs.
+func (r *Registry) Compare(name string, ids ...int) (lines []Plot, err error) {
+	for i, id := range ids {
+		var h []*Stats
+		if h, err = r.History(id); err != nil {
+			return
+		}
+		line := NewPlot("", len(h))
+		line.Color = RunColors[i%len(RunColors)]
+		r.Lock()
+		line.Label = fmt.Sprintf("%d: %s %s", id, r.runs[id].Name, name)
+		r.Unlock()
+		for j, s := range h {
+			var val interface{}
+			if val, err = s.Get(name); err != nil {
+				return
+			}
+			switch y := val.(type) {
+			case float64:
+				line.Data[j] = [3]float64{float64(j), y, 0}
+			case int:
+				line.Data[j] = [3]float64{float64(j), float64(y), 0}
+			default:
+				err = fmt.Errorf("Stats field %s could not be converted to float", name)
+				return
+			}
+		}
+		lines = append(lines, line)
+	}
+	return
+}
+
+// HandleHTTP registers handlers to serve the list of runs and their history via HTTP.
+//
+//	/runs              list of runs
+//	/runs/<id>         stats for each generation of the run
+//	/compare/<field>   comparison plot for runs given by the runs=<id>,<id>... parameter
+func (r *Registry) HandleHTTP() {
+	http.HandleFunc("/runs", func(w http.ResponseWriter, req *http.Request) {
+		sendJSON(w, req, r.List())
+	})
+	http.HandleFunc("/runs/", func(w http.ResponseWriter, req *http.Request) {
+		id, err := strconv.Atoi(req.URL.Path[len("/runs/"):])
+		if err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		data, err := r.getPlotStats(id)
+		if err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		sendJSON(w, req, data)
+	})
+	http.HandleFunc("/compare/", func(w http.ResponseWriter, req *http.Request) {
+		ids := []int{}
+		for _, arg := range strings.Split(req.FormValue("runs"), ",") {
+			if id, err := strconv.Atoi(arg); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		lines, err := r.Compare(req.URL.Path[len("/compare/"):], ids...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sendJSON(w, req, lines)
+	})
+}
+
+// ListenAndServe method serves the list of runs via HTTP on port without a live run, e.g. to
+// compare the results from a set of batch runs. HTML docs should be under the webRoot directory.
+func (r *Registry) ListenAndServe(port, webRoot string) {
+	r.HandleHTTP()
+	http.Handle("/", http.FileServer(http.Dir(webRoot)))
+	log.Println("starting web server on", port)
+	log.Fatal(http.ListenAndServe(port, nil))
+}
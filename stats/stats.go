@@ -11,9 +11,11 @@ import (
 
 // Formatting for logging implemented in Stats.String() method.
 // The default set of columns and format strings are set on initialisation.
+// If Headless is set then MainLoop will serve the web interface without launching a browser.
 var (
 	BrowserWidth  = 850
 	BrowserHeight = 950
+	Headless      = false
 	LogColumn     = []string{"Gen", "Evals", "Fit.Max", "Fit.Avg", "Fit.Std",
 		"Size.Avg", "Size.Max", "Depth.Avg", "Depth.Max"}
 	LogFormatFloat  = "%.3g"
@@ -24,11 +26,27 @@ var (
 )
 
 // The Stats structure holds the statistics for the give Population.
+// Notes records any changes made to the run via the control API at this generation.
+// Diversity measures are: Unique - fraction of individuals with unique code, Entropy - entropy of the
+// fitness histogram, Distance - subtree overlap distance between a sample of pairs of individuals and
+// Pheno - distance between the outputs for a sample of pairs, which is set by the Phenotypic method.
+// Ops has the counts for each variation and decorator used to create this generation.
+// Valid is the validation fitness of the best individual if the Logger has a Validate function.
+// Novelty and Archive are the novelty of each individual and the archive size if the Logger has
+// a novelty search Archive. Coverage is the fraction of filled cells if the Logger has Elites.
 type Stats struct {
 	Gen, Evals       int
 	Fit, Size, Depth StatsData
+	Valid            float64
+	Unique, Entropy  float64
+	Distance, Pheno  StatsData
+	Novelty          StatsData
+	Archive          int
+	Coverage         float64
 	FitHist          []int
+	Ops              map[string]*gp.OpStats
 	Best             *gp.Individual
+	Notes            []string
 }
 
 // The StatsData struct holds the values for a single metric.
@@ -55,15 +73,25 @@ func Create(pop gp.Population, gen, evals int) *Stats {
 			s.FitHist[bin]++
 		}
 	}
+	s.diversity(pop)
+	s.Ops = pop.OpStats()
 	return s
 }
 
-// update stats data, calc running mean and variance
+// update stats data for each individual in the population
 func updateStats(pop gp.Population, getval func(*gp.Individual) float64) StatsData {
+	values := make([]float64, len(pop))
+	for i, ind := range pop {
+		values[i] = getval(ind)
+	}
+	return summarise(values)
+}
+
+// calc stats for list of values using running mean and variance
+func summarise(values []float64) StatsData {
 	d := StatsData{Min: 1e99, Max: 1e-99}
 	var oldM, oldS float64
-	for i, ind := range pop {
-		val := getval(ind)
+	for i, val := range values {
 		if val > d.Max {
 			d.Max, d.MaxIndex = val, i
 		}
@@ -78,8 +106,8 @@ func updateStats(pop gp.Population, getval func(*gp.Individual) float64) StatsDa
 			oldM, oldS = d.Avg, d.Std
 		}
 	}
-	if len(pop) > 1 {
-		d.Std = math.Sqrt(d.Std / float64(len(pop)-1))
+	if len(values) > 1 {
+		d.Std = math.Sqrt(d.Std / float64(len(values)-1))
 	}
 	return d
 }
@@ -102,8 +130,17 @@ func (d StatsData) Get(name string) (val interface{}, err error) {
 
 // The Get method returns the value for the named Stats field.
 // If dot notation is used then it will extract the subfield from the StatsData struct.
+// Operator counts are given as Ops.<name>.<field>, e.g. Ops.CxOnePoint.Better.
 func (s *Stats) Get(name string) (val interface{}, err error) {
 	names := strings.Split(name, ".")
+	if names[0] == "Ops" && len(names) > 2 {
+		last := len(names) - 1
+		op := s.Ops[strings.Join(names[1:last], ".")]
+		if op == nil {
+			op = &gp.OpStats{}
+		}
+		return getField(reflect.ValueOf(op), names[last])
+	}
 	if val, err = getField(reflect.ValueOf(s), names[0]); err != nil {
 		return
 	}
@@ -1,10 +1,14 @@
 package stats
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/jnb666/gogp/gp"
 	"github.com/jnb666/gogp/num"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -13,13 +17,18 @@ var exp = `Gen      Evals    FitMax   FitAvg   FitStd   SizeAvg  SizeMax  DepthA
 
 var fields = []string{"Gen", "Evals", "Fit.Max", "Fit.Avg", "Fit.Std", "Size.Max", "Depth.Max"}
 
-func getStats(t *testing.T, gen int) *Stats {
+func getPopulation() gp.Population {
 	pset := gp.CreatePrimSet(1, "x")
 	pset.Add(num.Add, num.Sub, num.Mul, num.Div, num.Neg, num.V(0), num.V(1))
 	pop := gp.CreatePopulation(1000, gp.GenFull(pset, 1, 3))
 	for i := range pop {
 		pop[i].Fitness = rand.Float64()
 	}
+	return pop
+}
+
+func getStats(t *testing.T, gen int) *Stats {
+	pop := getPopulation()
 	s := Create(pop, gen, len(pop))
 	t.Log(s)
 	return s
@@ -44,3 +53,205 @@ func TestStats(t *testing.T) {
 		t.Error("expected error for missing field")
 	}
 }
+
+// test diversity measures
+func TestDiversity(t *testing.T) {
+	gp.SetSeed(1)
+	pop := getPopulation()
+	s := Create(pop, 0, len(pop))
+	s.Phenotypic(pop, func(code gp.Expr) []float64 {
+		out := make([]float64, 10)
+		for i := range out {
+			out[i] = float64(code.Eval(num.V(i)).(num.V))
+		}
+		return out
+	})
+	t.Logf("unique=%.3f entropy=%.3f distance=%+v pheno=%+v", s.Unique, s.Entropy, s.Distance, s.Pheno)
+	if s.Unique <= 0 || s.Unique > 1 || s.Distance.Avg <= 0 || s.Distance.Max > 1 || s.Pheno.Avg <= 0 {
+		t.Error("diversity looks wrong")
+	}
+	same := make(gp.Population, 10)
+	for i := range same {
+		same[i] = pop[0].Clone()
+	}
+	s = Create(same, 0, len(same))
+	if s.Unique != 0.1 || s.Distance.Avg != 0 || s.Entropy != 0 {
+		t.Errorf("expected no diversity: unique=%g distance=%+v entropy=%g", s.Unique, s.Distance, s.Entropy)
+	}
+	plots, err := getStatsPlots([]*Stats{s}, "Unique")
+	if err != nil || len(plots) != 1 {
+		t.Error("error getting plot", err)
+	}
+}
+
+// test operator stats
+func TestOpStats(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(num.Add, num.Sub, num.Mul, num.Div, num.Neg, num.V(0), num.V(1))
+	problem := &gp.Model{
+		PrimitiveSet:  pset,
+		Generator:     gp.GenFull(pset, 1, 3),
+		PopSize:       200,
+		Fitness:       func(code gp.Expr) (float64, bool) { return 1 / float64(len(code)), true },
+		Offspring:     gp.Tournament(3),
+		Mutate:        gp.MutUniform(gp.GenGrow(pset, 0, 2)),
+		MutateProb:    0.2,
+		Crossover:     gp.CxOnePoint(),
+		CrossoverProb: 0.5,
+		Threads:       1,
+	}
+	problem.AddDecorator(gp.SizeLimit(10))
+	l := NewLogger(3, 1)
+	l.Registry = nil
+	problem.Run(l)
+	s := l.history[len(l.history)-1]
+	for name, op := range s.Ops {
+		t.Logf("%s: %+v", name, *op)
+	}
+	cx := s.Ops["CxOnePoint"]
+	if cx == nil || cx.Applied == 0 || cx.Better+cx.Worse+cx.Neutral+cx.Rejected != cx.Applied {
+		t.Error("invalid crossover stats")
+	}
+	if op := s.Ops["SizeLimit(10)"]; op == nil || op.Rejected == 0 {
+		t.Error("expected some rejections from SizeLimit")
+	}
+	if val, err := s.Get("Ops.CxOnePoint.Better"); err != nil || val.(int) != cx.Better {
+		t.Error("error getting op stats", val, err)
+	}
+	if plots, _ := getStatsPlots(l.history, "Ops"); len(plots) != len(s.Ops) {
+		t.Error("expected plot for each op")
+	}	// children which were not changed are not passed to the decorator
+	cross := gp.CxOnePoint()
+	cross.AddDecorator(gp.SizeLimit(0))
+	pop := gp.Population{gp.Create(gp.Expr{num.V(0)}), gp.Create(gp.Expr{num.V(1)})}
+	cross.Variate(pop)
+	if ops := pop.OpStats(); len(ops) != 0 {
+		t.Error("unchanged children should not be rejected", ops)
+	}
+}
+
+// test streaming stats to a web client
+func TestEvents(t *testing.T) {
+	gp.SetSeed(1)
+	l := NewLogger(10, 1)
+	server := httptest.NewServer(http.HandlerFunc(l.eventsHandler))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	l.Log(getPopulation(), 0, 1000)
+	r := bufio.NewReader(resp.Body)
+	for _, prefix := range []string{"event: stats", "data: {"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Log(strings.TrimSpace(line))
+		if !strings.HasPrefix(line, prefix) {
+			t.Error("expected", prefix)
+		}
+	}
+}
+
+// test recording multiple runs in a registry
+func TestRuns(t *testing.T) {
+	gp.SetSeed(1)
+	reg := &Registry{}
+	l := &Logger{MaxGen: 1, TargetFitness: 1, Name: "test", Registry: reg}
+	for run := 0; run < 2; run++ {
+		for gen := 0; gen <= run; gen++ {
+			l.Log(getPopulation(), gen, 1000)
+		}
+		l.Reset()
+	}
+	list := reg.List()
+	t.Logf("%+v", list)
+	if len(list) != 2 || list[1].Gen != 1 || !list[1].Done {
+		t.Error("runs not recorded as expected")
+	}
+	lines, err := reg.Compare("Size.Max", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(lines)
+	if len(lines) != 2 || len(lines[1].Data) != 2 {
+		t.Error("wrong number of points in comparison plot")
+	}
+	if _, err = reg.Compare("Fit.Max", 2); err == nil {
+		t.Error("expected error for missing run")
+	}
+	// only keep history for the last MaxRuns runs
+	reg.MaxRuns = 2
+	l.Log(getPopulation(), 0, 1000)
+	l.Reset()
+	list = reg.List()
+	if _, err = reg.History(0); err == nil || len(list) != 3 || list[0].Gen != 0 || list[0].Best == "" {
+		t.Errorf("expected history for first run to be discarded: %+v", list)
+	}
+	if h, err := reg.History(1); err != nil || len(h) != 2 {
+		t.Error("expected history for second run to be kept")
+	}
+}
+
+// test changing parameters and stopping a run via the control API
+func TestControl(t *testing.T) {
+	gp.SetSeed(1)
+	m := &gp.Model{Offspring: gp.Tournament(3), MutateProb: 0.2, CrossoverProb: 0.5}
+	l := &Logger{MaxGen: 10, TargetFitness: 1}
+	l.Control(m)
+	if size := l.Status().TournamentSize; size == nil || *size != 3 {
+		t.Error("expected tournament size of 3")
+	}
+	prob, tsize := 0.3, 5
+	if err := l.SetParams(Params{MutateProb: &prob, TournamentSize: &tsize}); err != nil {
+		t.Fatal(err)
+	}
+	pop := getPopulation()
+	if l.Log(pop, 0, len(pop)) {
+		t.Error("run should not be done")
+	}
+	notes := l.history[0].Notes
+	t.Log(notes)
+	if m.MutateProb != 0.3 || m.CrossoverProb != 0.5 || m.Offspring.String() != "Tournament(5)" || len(notes) != 2 {
+		t.Error("parameters not updated as expected")
+	}
+	// max generations applies from the generation where it is changed
+	maxGen := 1
+	l.SetParams(Params{MaxGen: &maxGen})
+	if !l.Log(pop, 1, len(pop)) || len(l.history[1].Notes) != 1 {
+		t.Error("run should be done")
+	}
+	l.Stop()
+	if !l.Log(pop, 2, len(pop)) || !l.Status().Stopped || len(l.history[2].Notes) != 1 {
+		t.Error("run should be stopped")
+	}
+	// tournament size cannot be set for other selectors
+	m.Offspring = gp.NoveltySelect(m.Offspring, gp.NewArchive(5, 1, 0), 0.5)
+	if err := l.SetParams(Params{TournamentSize: &tsize}); err == nil {
+		t.Error("expecting error setting tournament size")
+	}
+}
+
+// test early stopping on validation fitness
+func TestValidate(t *testing.T) {
+	l := NewLogger(100, 1)
+	l.Registry = nil
+	l.Validate = func(code gp.Expr) float64 { return 0.5 }
+	l.Patience = 5
+	gen := 0
+	var first *gp.Individual
+	for ; !l.Log(getPopulation(), gen, 1000); gen++ {
+		if gen == 0 {
+			first = l.history[0].Best
+		}
+	}
+	if gen != 5 || l.history[gen].Valid != 0.5 {
+		t.Error("expected early stop at generation 5, got", gen)
+	}
+	if best := l.BestValid(); best == nil || best.Id != first.Id {
+		t.Error("expected best validation individual from generation 0", best)
+	}
+}
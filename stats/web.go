@@ -4,10 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/jnb666/gogp/gp"
-	"github.com/mattn/go-gtk/gtk"
-	"github.com/mattn/go-webkit/webkit"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"sync"
 )
@@ -26,6 +25,11 @@ var options = []opt{
 	{"Fit", "fitness"},
 	{"Size", "size"},
 	{"Depth", "depth"},
+	{"Unique", "unique"},
+	{"Distance", "distance"},
+	{"Entropy", "fit entropy"},
+	{"Ops", "op success"},
+	{"Rejects", "op rejects"},
 }
 
 // Logger struct holds stats generated by model for each generation.
@@ -33,12 +37,33 @@ var options = []opt{
 // It implements the gp.Logger interface.
 // If OnStep is non nil then it is called with best individual at each generation.
 // If OnDone is non nil then it is called with best individual at end of run.
+// If Interactive is set then the web client steps through each generation of the run, else
+// the run proceeds at full speed and each generation is pushed to clients via /events.
+// If Registry is non nil then the history for each run is recorded there, labelled with Name.
+// If Outputs is non nil then it is used to calculate the phenotypic diversity - it should return
+// the output of the code for each test case.
+// If Genealogy is non nil then the lineage of each new individual is recorded there.
+// If Validate is non nil then it is called to calculate the validation fitness of the best individual
+// for each generation. If Patience is also set then the run is stopped early if the validation fitness
+// has not improved for this number of generations, and BestValid returns the individual with the
+// highest validation fitness.
+// If Archive is non nil then the novelty and archive size are recorded for novelty search.
+// If Elites is non nil then the coverage of the MAP-Elites archive is recorded.
 type Logger struct {
 	sync.Mutex
 	MaxGen        int
 	TargetFitness float64
 	PrintStats    bool
 	PrintBest     bool
+	Interactive   bool
+	Name          string
+	Registry      *Registry
+	Outputs       func(code gp.Expr) []float64
+	Genealogy     *gp.Genealogy
+	Validate      func(code gp.Expr) float64
+	Patience      int
+	Archive       *gp.Archive
+	Elites        *gp.Elites
 	OnStep        func(best *gp.Individual)
 	OnDone        func(best *gp.Individual)
 	history       []*Stats
@@ -48,14 +73,21 @@ type Logger struct {
 	svgplotter    func(gp.Population) []byte
 	svgplot       []byte
 	bestFit       float64
+	bestValid     float64
+	bestValidGen  int
+	bestValidInd  *gp.Individual
 	done          bool
 	step          chan stepMsg
 	start         chan empty
+	clients       map[chan []byte]bool
+	run           *Run
+	ctrl          *control
 }
 
 // The PlotStats struct holds stats data which is served via HTTP in JSON format
 type PlotStats struct {
 	Done        bool
+	Interactive bool
 	Gen, MaxGen int
 	Headers     []string
 	Stats       [][]string
@@ -83,17 +115,20 @@ type Plot struct {
 	Color string       `json:"color"`
 }
 
-// NewLogger creates and retuns a new logger struct
+// NewLogger creates and retuns a new logger struct which records its runs in the DefaultRegistry
 func NewLogger(maxGen int, targetFitness float64) *Logger {
-	l := Logger{MaxGen: maxGen, TargetFitness: targetFitness}
+	l := Logger{MaxGen: maxGen, TargetFitness: targetFitness, Registry: DefaultRegistry}
 	l.options = append([]opt{}, options...)
 	return &l
 }
 
-// InitChan initialises the channels used for ipc for the web interface
+// InitChan initialises the channels used for ipc for the web interface.
+// The step channel is only used if the Logger is in Interactive mode.
 func (l *Logger) InitChan() {
 	l.start = make(chan empty)
-	l.step = make(chan stepMsg)
+	if l.Interactive {
+		l.step = make(chan stepMsg)
+	}
 }
 
 // Reset reinitialisates the Logger struct. Call this before rerunning.
@@ -102,19 +137,48 @@ func (l *Logger) Reset() {
 	defer l.Unlock()
 	l.history = []*Stats{}
 	l.bestFit = 0
+	l.bestValid, l.bestValidGen, l.bestValidInd = 0, 0, nil
 	l.done = false
+	if l.run != nil {
+		l.Registry.finish(l.run)
+		l.run = nil
+	}
+	l.publish("reset", []byte("{}"))
+}
+
+// BestValid returns the best individual from the generation with the highest validation fitness,
+// or nil if Validate is not set.
+func (l *Logger) BestValid() *gp.Individual {
+	l.Lock()
+	defer l.Unlock()
+	return l.bestValidInd
 }
 
 // update history and plots
-func (l *Logger) update(s *Stats, pop gp.Population, gen int) bool {
+func (l *Logger) update(s *Stats, pop gp.Population, gen int, stop bool) bool {
 	l.Lock()
 	defer l.Unlock()
-	done := gen >= l.MaxGen || s.Fit.Max >= l.TargetFitness
+	done := stop || gen >= l.MaxGen || s.Fit.Max >= l.TargetFitness
+	stopped := false
+	if l.Validate != nil {
+		if gen == 0 || s.Valid > l.bestValid {
+			l.bestValid, l.bestValidGen, l.bestValidInd = s.Valid, gen, s.Best.Clone()
+		}
+		if l.Patience > 0 && gen-l.bestValidGen >= l.Patience && !done {
+			done, stopped = true, true
+		}
+	}
 	if l.PrintStats {
 		fmt.Println(s)
 		if s.Fit.Max >= l.TargetFitness {
 			fmt.Println("** SUCCESS **")
 		}
+		if stopped {
+			fmt.Printf("** EARLY STOP - no improvement in validation fitness since generation %d **\n", l.bestValidGen)
+		}
+		for _, line := range s.Notes {
+			fmt.Println(line)
+		}
 	}
 	if l.PrintBest && s.Fit.Max > l.bestFit {
 		l.bestFit = s.Fit.Max
@@ -126,6 +190,12 @@ func (l *Logger) update(s *Stats, pop gp.Population, gen int) bool {
 		l.history = append(l.history, s)
 	}
 	l.done = done
+	if l.Registry != nil {
+		if l.run == nil {
+			l.run = l.Registry.start(l.Name)
+		}
+		l.Registry.update(l.run, s, done)
+	}
 	if l.plotters != nil {
 		l.plots = make([]Plot, len(l.plotters))
 		for i, plotter := range l.plotters {
@@ -135,6 +205,7 @@ func (l *Logger) update(s *Stats, pop gp.Population, gen int) bool {
 	if l.svgplotter != nil {
 		l.svgplot = l.svgplotter(pop)
 	}
+	l.publishStats(s)
 	return done
 }
 
@@ -142,7 +213,27 @@ func (l *Logger) update(s *Stats, pop gp.Population, gen int) bool {
 // History stats are stored so they can be served via ServeHTTP.
 func (l *Logger) Log(pop gp.Population, gen, evals int) bool {
 	stats := Create(pop, gen, evals)
-	done := l.update(stats, pop, gen)
+	if l.Outputs != nil {
+		stats.Phenotypic(pop, l.Outputs)
+	}
+	if l.Genealogy != nil {
+		l.Genealogy.Add(pop, gen)
+	}
+	if l.Validate != nil {
+		stats.Valid = l.Validate(stats.Best.Code)
+	}
+	if l.Archive != nil {
+		stats.Novelty = updateStats(pop, func(ind *gp.Individual) float64 { return ind.Novelty })
+		stats.Archive = l.Archive.Len()
+	}
+	if l.Elites != nil {
+		stats.Coverage = l.Elites.Coverage()
+	}
+	stop, resume := l.applyControl(pop, stats)
+	done := l.update(stats, pop, gen, stop)
+	if resume != nil {
+		<-resume
+	}
 	if l.OnStep != nil {
 		l.OnStep(pop[stats.Fit.MaxIndex])
 	}
@@ -168,6 +259,21 @@ func (l *Logger) Log(pop gp.Population, gen, evals int) bool {
 			}
 			<-l.start
 		}
+	} else if l.start != nil {
+		select {
+		case <-l.start:
+			if Debug {
+				log.Println("got interrupt - quit run")
+			}
+			return true
+		default:
+		}
+		if done {
+			if Debug {
+				log.Println("end of run - wait for restart")
+			}
+			<-l.start
+		}
 	}
 	return done
 }
@@ -188,11 +294,29 @@ func (l *Logger) ListenAndServe(port, webRoot string) {
 		http.HandleFunc("/step", func(w http.ResponseWriter, r *http.Request) {
 			sendJSON(w, r, <-l.step)
 		})
+	}
+	if l.start != nil {
 		http.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
 			l.start <- empty{}
 		})
 	}
+	http.HandleFunc("/events", l.eventsHandler)
+	if l.ctrl != nil {
+		http.HandleFunc("/control/", l.controlHandler(len("/control/")))
+	}
 	http.HandleFunc("/stats/", l.statsHandler(len("/stats/")))
+	if l.Outputs != nil {
+		l.options = append(l.options, opt{"Pheno", "phenotype"})
+	}
+	if l.Validate != nil {
+		l.options = append(l.options, opt{"Valid", "validation"})
+	}
+	if l.Archive != nil {
+		l.options = append(l.options, opt{"Novelty", "novelty"}, opt{"Archive", "archive size"})
+	}
+	if l.Elites != nil {
+		l.options = append(l.options, opt{"Coverage", "coverage"})
+	}
 	http.HandleFunc("/plot/List", func(w http.ResponseWriter, r *http.Request) {
 		sendJSON(w, r, l.options)
 	})
@@ -205,6 +329,9 @@ func (l *Logger) ListenAndServe(port, webRoot string) {
 	http.HandleFunc("/plot/Hist", l.histogramPlotHandler())
 	http.HandleFunc("/plot/", l.statsPlotHandler(len("/plot/")))
 	http.HandleFunc("/graph", l.graphHandler())
+	if l.Registry != nil {
+		l.Registry.HandleHTTP()
+	}
 	http.Handle("/", http.FileServer(http.Dir(webRoot)))
 	log.Println("starting web server on", port)
 	if Debug {
@@ -248,8 +375,7 @@ func (l *Logger) graphHandler() func(http.ResponseWriter, *http.Request) {
 			return
 		}
 		code := l.history[len(l.history)-1].Best.Code
-		graph := code.Graph("best")
-		data, err := gp.Layout(graph, "svg")
+		data, err := code.Render("svg")
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -266,6 +392,7 @@ func (l *Logger) getPlotStats(firstGen int) (data PlotStats) {
 	defer l.Unlock()
 	last := len(l.history) - 1
 	data.Done = l.done
+	data.Interactive = l.step != nil
 	data.Gen = last
 	data.MaxGen = l.MaxGen
 	if last >= 0 {
@@ -340,8 +467,30 @@ func (l *Logger) RegisterSVGPlot(name string, plotter func(gp.Population) []byte
 
 // Get the history data for the named field in a suitable format for plotting.
 // Returns an error if name is not a valid field. Standard deviation is shown as a range.
+// If the field is a single value rather than a StatsData struct then a single line is returned.
 func getStatsPlots(h []*Stats, name string) (lines []Plot, err error) {
 	var val interface{}
+	if name == "Ops" || name == "Rejects" {
+		return getOpsPlots(h, name), nil
+	}
+	if len(h) > 0 {
+		if val, err = h[0].Get(name); err != nil {
+			return
+		}
+		switch val.(type) {
+		case float64, int:
+			line := NewPlot(name, len(h))
+			line.Color = "#ff0000"
+			for j, stats := range h {
+				val, _ = stats.Get(name)
+				if n, ok := val.(int); ok {
+					val = float64(n)
+				}
+				line.Data[j] = [3]float64{float64(j), val.(float64), 0}
+			}
+			return []Plot{line}, nil
+		}
+	}
 	lines = make([]Plot, 3)
 	colors := []string{"#ff0000", "#0000ff", "#b0b0ff"}
 	for i, field := range []string{"Max", "Avg", "Std"} {
@@ -375,6 +524,46 @@ func getStatsPlots(h []*Stats, name string) (lines []Plot, err error) {
 	return
 }
 
+// Get a line for each operator: the percentage of children which are better than their parents for
+// Ops, or the number of rejected children for Rejects.
+func getOpsPlots(h []*Stats, name string) []Plot {
+	names := []string{}
+	for _, stats := range h {
+		for op := range stats.Ops {
+			if !contains(names, op) {
+				names = append(names, op)
+			}
+		}
+	}
+	sort.Strings(names)
+	lines := []Plot{}
+	for i, op := range names {
+		line := NewPlot(op, len(h))
+		line.Color = RunColors[i%len(RunColors)]
+		for j, stats := range h {
+			line.Data[j][0] = float64(j)
+			if s := stats.Ops[op]; s != nil {
+				if name == "Rejects" {
+					line.Data[j][1] = float64(s.Rejected)
+				} else if s.Applied > 0 {
+					line.Data[j][1] = 100 * float64(s.Better) / float64(s.Applied)
+				}
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 // NewPlot returns a new line Plot struct with given label and size
 func NewPlot(label string, size int) Plot {
 	p := Plot{}
@@ -397,34 +586,35 @@ func NewBarPlot(label string, size int) Plot {
 	return p
 }
 
-// StartBrowser launches a web browser with given url and size
-func StartBrowser(url string, width, height int) {
-	gtk.Init(nil)
-	window := gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
-	window.SetTitle("gogp")
-	window.Connect("destroy", gtk.MainQuit)
-	swin := gtk.NewScrolledWindow(nil, nil)
-	swin.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
-	swin.SetShadowType(gtk.SHADOW_IN)
-	webview := webkit.NewWebView()
-	swin.Add(webview)
-	window.Add(swin)
-	window.SetSizeRequest(width, height)
-	webview.LoadUri(url)
-	window.ShowAll()
-	gtk.Main()
-}
-
 // MainLoop function runs a model repeatedly with given logger.
-// Control of step and restart is controlled via web interface.
+// Control of step and restart is controlled via web interface. A browser window is launched
+// to view the results unless Headless is set or the package was built without the gtk tag,
+// in which case the web server is run in the foreground and should be accessed remotely.
 func MainLoop(problem *gp.Model, logger *Logger, port, webRoot string) {
+	if Headless || !HasBrowser {
+		Serve(problem, logger, port, webRoot)
+		return
+	}
+	go Serve(problem, logger, port, webRoot)
+	StartBrowser("http://localhost"+port, BrowserWidth, BrowserHeight)
+}
+
+// Serve function runs a model repeatedly in the background and serves the web interface on port.
+// If the logger has Elites then the model is run using the MAP-Elites algorithm.
+// The run can be controlled via the /control API. This routine won't return.
+func Serve(problem *gp.Model, logger *Logger, port, webRoot string) {
 	logger.InitChan()
-	go logger.ListenAndServe(port, webRoot)
+	logger.Control(problem)
 	go func() {
 		for {
-			problem.Run(logger)
+			if logger.Elites != nil {
+				problem.RunElites(logger.Elites, logger)
+				logger.Elites.Reset()
+			} else {
+				problem.Run(logger)
+			}
 			logger.Reset()
 		}
 	}()
-	StartBrowser("http://localhost"+port, BrowserWidth, BrowserHeight)
+	logger.ListenAndServe(port, webRoot)
 }
@@ -18,8 +18,9 @@ type Options struct {
 	TournSize, MaxGen                        int
 	PopSize, Threads                         int
 	TargetFitness, CrossoverProb, MutateProb float64
-	Plot, Verbose                            bool
+	Plot, Verbose, Headless, Step            bool
 	Seed                                     int64
+	Port                                     string
 }
 
 var DefaultOptions = Options{
@@ -30,6 +31,7 @@ var DefaultOptions = Options{
 	Threads:       runtime.NumCPU(),
 	CrossoverProb: 0.5,
 	MutateProb:    0.2,
+	Port:          ":8080",
 }
 
 // ParseFlags reads command flags and sets no. of threads and random seed.
@@ -44,6 +46,9 @@ func ParseFlags(opts *Options) {
 	flag.Float64Var(&opts.MutateProb, "mutprob", opts.MutateProb, "mutation probability")
 	flag.BoolVar(&opts.Plot, "plot", opts.Plot, "serve plot data via http")
 	flag.BoolVar(&opts.Verbose, "v", opts.Verbose, "print out best individual so far")
+	flag.BoolVar(&opts.Headless, "headless", opts.Headless, "serve plot data without launching a browser")
+	flag.BoolVar(&opts.Step, "step", opts.Step, "step through each generation from the web interface")
+	flag.StringVar(&opts.Port, "port", opts.Port, "address to serve plot data on")
 	flag.Parse()
 	gp.SetSeed(opts.Seed)
 	runtime.GOMAXPROCS(opts.Threads)
@@ -0,0 +1,260 @@
+// Package vector provides a vector type of floating point values and associated operations for gogp.
+// Element-wise operations broadcast vectors of length 1 as scalars; where the lengths of the
+// arguments differ otherwise the result has the length of the shortest argument.
+package vector
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"math"
+	"strings"
+)
+
+// Division by a value smaller than this returns 0.
+var DIVIDE_PROTECT = 1e-10
+
+// Element-wise arithmetic.
+var (
+	Add  = ElementOp("+", func(a, b float64) float64 { return a + b })
+	Sub  = ElementOp("-", func(a, b float64) float64 { return a - b })
+	Mul  = ElementOp("*", func(a, b float64) float64 { return a * b })
+	Div  = ElementOp("/", protected_divide)
+	Neg  = ElementUnary("-", func(a float64) float64 { return -a })
+	Abs  = ElementUnary("abs", math.Abs)
+	Sqrt = ElementUnary("sqrt", func(a float64) float64 { return math.Sqrt(math.Abs(a)) })
+)
+
+// Reductions which return a vector of length 1.
+var (
+	Sum  = Reduce("sum", sum)
+	Mean = Reduce("mean", mean)
+	Max  = Reduce("max", func(v V) float64 { return extreme(v, 1) })
+	Min  = Reduce("min", func(v V) float64 { return extreme(v, -1) })
+	Std  = Reduce("std", std)
+)
+
+// Diff returns the difference between successive elements.
+var Diff = Unary("diff", func(v V) V {
+	if len(v) < 2 {
+		return V{0}
+	}
+	out := make(V, len(v)-1)
+	for i := range out {
+		out[i] = v[i+1] - v[i]
+	}
+	return out
+})
+
+func protected_divide(a, b float64) float64 {
+	if b > -DIVIDE_PROTECT && b < DIVIDE_PROTECT {
+		return 0
+	}
+	return a / b
+}
+
+func sum(v V) float64 {
+	total := 0.0
+	for _, x := range v {
+		total += x
+	}
+	return total
+}
+
+func mean(v V) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	return sum(v) / float64(len(v))
+}
+
+func std(v V) float64 {
+	if len(v) < 2 {
+		return 0
+	}
+	avg, total := mean(v), 0.0
+	for _, x := range v {
+		total += (x - avg) * (x - avg)
+	}
+	return math.Sqrt(total / float64(len(v)-1))
+}
+
+func extreme(v V, sign float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	val := v[0]
+	for _, x := range v[1:] {
+		if sign*x > sign*val {
+			val = x
+		}
+	}
+	return val
+}
+
+// V is a vector value which implements the gp.Opcode interface
+type V []float64
+
+// Scalar returns a vector of length 1 with the given value.
+func Scalar(x float64) V { return V{x} }
+
+// Arity method returns the number of arguments for the opcode
+func (v V) Arity() int { return 0 }
+
+// Eval method for a vector constant returns the value
+func (v V) Eval(args ...gp.Value) gp.Value { return v }
+
+// String method returns the name of the opcode
+func (v V) String() string {
+	if len(v) == 1 {
+		return fmt.Sprint(v[0])
+	}
+	s := make([]string, len(v))
+	for i, x := range v {
+		s[i] = fmt.Sprint(x)
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// Format method is called by Expr Format() to return a expression in a human readable format
+func (v V) Format(args ...string) string { return v.String() }
+
+// apply function to each pair of elements, broadcasting if either has length 1
+func elementwise(a, b V, fun func(a, b float64) float64) V {
+	n := len(a)
+	switch {
+	case len(a) == 1:
+		n = len(b)
+	case len(b) == 1:
+	case len(b) < n:
+		n = len(b)
+	}
+	out := make(V, n)
+	for i := range out {
+		x, y := a[0], b[0]
+		if len(a) > 1 {
+			x = a[i]
+		}
+		if len(b) > 1 {
+			y = b[i]
+		}
+		out[i] = fun(x, y)
+	}
+	return out
+}
+
+// ElementOp returns a binary operator which applies fun to each element of its arguments.
+func ElementOp(name string, fun func(a, b float64) float64) gp.Opcode {
+	return Op(name, func(a, b V) V { return elementwise(a, b, fun) })
+}
+
+// ElementUnary returns a unary operator which applies fun to each element of its argument.
+func ElementUnary(name string, fun func(a float64) float64) gp.Opcode {
+	return Unary(name, func(a V) V {
+		out := make(V, len(a))
+		for i, x := range a {
+			out[i] = fun(x)
+		}
+		return out
+	})
+}
+
+// Reduce returns a unary operator which returns a vector of length 1 with the result of fun.
+func Reduce(name string, fun func(V) float64) gp.Opcode {
+	return Unary(name, func(a V) V { return V{fun(a)} })
+}
+
+// Window returns a unary operator which applies fun to a sliding window of n elements
+// of its argument, e.g. Window("mavg", 3, Mean) for a moving average. If the argument has
+// fewer than n elements then fun is applied to the whole vector.
+func Window(name string, n int, fun func(V) float64) gp.Opcode {
+	return Unary(fmt.Sprintf("%s%d", name, n), func(a V) V {
+		if len(a) <= n {
+			return V{fun(a)}
+		}
+		out := make(V, len(a)-n+1)
+		for i := range out {
+			out[i] = fun(a[i : i+n])
+		}
+		return out
+	})
+}
+
+// MovingAverage returns a Window operator which calculates the mean of each n elements.
+func MovingAverage(n int) gp.Opcode {
+	return Window("mavg", n, mean)
+}
+
+// Shift returns a unary operator which shifts the elements n places to the right if n is
+// positive, or to the left if negative, with zeros shifted in.
+func Shift(n int) gp.Opcode {
+	return Unary(fmt.Sprintf("shift%d", n), func(a V) V {
+		out := make(V, len(a))
+		for i := range a {
+			if j := i - n; j >= 0 && j < len(a) {
+				out[i] = a[j]
+			}
+		}
+		return out
+	})
+}
+
+// Func constructor returns a vector function with given arity
+// which implements the gp.Opcode interface
+func Func(name string, arity int, fun func([]V) V) gp.Opcode {
+	return vecFunc{gp.Function(name, arity), fun}
+}
+
+type vecFunc struct {
+	gp.Opcode
+	fun func([]V) V
+}
+
+func (o vecFunc) Eval(iargs ...gp.Value) gp.Value {
+	args := make([]V, len(iargs))
+	for i, iarg := range iargs {
+		args[i] = iarg.(V)
+	}
+	return o.fun(args)
+}
+
+// Term constructor returns a vector terminal operator which implements the gp.Opcode interface
+func Term(name string, fun func() V) gp.Opcode {
+	return termOp{gp.Terminal(name), fun}
+}
+
+type termOp struct {
+	gp.Opcode
+	fun func() V
+}
+
+func (o termOp) Eval(args ...gp.Value) gp.Value {
+	return o.fun()
+}
+
+// Unary constructor returns a vector unary operator which implements the gp.Opcode interface
+func Unary(name string, fun func(a V) V) gp.Opcode {
+	return unaryOp{gp.Function(name, 1), fun}
+}
+
+type unaryOp struct {
+	gp.Opcode
+	fun func(a V) V
+}
+
+func (o unaryOp) Eval(args ...gp.Value) gp.Value {
+	return o.fun(args[0].(V))
+}
+
+// Op constructor returns a vector binary operator which implements the gp.Opcode interface
+func Op(name string, fun func(a, b V) V) gp.Opcode {
+	return vecOp{gp.Operator(name), fun}
+}
+
+type vecOp struct {
+	gp.Opcode
+	fun func(a, b V) V
+}
+
+func (o vecOp) Eval(args ...gp.Value) gp.Value {
+	return o.fun(args[0].(V), args[1].(V))
+}
@@ -0,0 +1,52 @@
+package vector
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+	"testing"
+)
+
+// test evaluating expressions
+func TestEval(t *testing.T) {
+	pset := gp.CreatePrimSet(1, "x")
+	x := pset.Var(0)
+	tests := []struct {
+		code   gp.Expr
+		format string
+		result string
+	}{
+		{gp.Expr{Add, x, Scalar(1)}, "(x + 1)", "[2 3 4 5]"},
+		{gp.Expr{Mul, x, V{1, 2}}, "(x * [1 2])", "[1 4]"},
+		{gp.Expr{Div, x, Sub, x, x}, "(x / (x - x))", "[0 0 0 0]"},
+		{gp.Expr{Sum, x}, "sum(x)", "10"},
+		{gp.Expr{Sub, x, Mean, x}, "(x - mean(x))", "[-1.5 -0.5 0.5 1.5]"},
+		{gp.Expr{Max, Neg, x}, "max(-(x))", "-1"},
+		{gp.Expr{Std, V{2, 4, 4, 4, 5, 5, 7, 9}}, "std([2 4 4 4 5 5 7 9])", "2.138089935299395"},
+		{gp.Expr{MovingAverage(2), x}, "mavg2(x)", "[1.5 2.5 3.5]"},
+		{gp.Expr{Shift(1), x}, "shift1(x)", "[0 1 2 3]"},
+		{gp.Expr{Shift(-2), x}, "shift-2(x)", "[3 4 0 0]"},
+		{gp.Expr{Diff, x}, "diff(x)", "[1 1 1]"},
+	}
+	for _, test := range tests {
+		val := test.code.Eval(V{1, 2, 3, 4}).(V)
+		format := test.code.Format()
+		t.Logf("%s = %s", format, val)
+		if format != test.format || val.String() != test.result {
+			t.Errorf("expected %s = %s", test.format, test.result)
+		}
+	}
+}
+
+// test generating and evaluating random expressions
+func TestGenerate(t *testing.T) {
+	gp.SetSeed(1)
+	pset := gp.CreatePrimSet(1, "x")
+	pset.Add(Add, Sub, Mul, Div, Neg, Sum, Mean, Std, MovingAverage(3), Shift(1), Scalar(2))
+	for _, ind := range gp.CreatePopulation(20, gp.GenRamped(pset, 1, 4)) {
+		val := ind.Code.Eval(V{1, 2, 3, 4, 5})
+		t.Log(ind.Code.Format(), "=>", val)
+		if _, ok := val.(V); !ok {
+			t.Error("expected vector result", fmt.Sprintf("%T", val))
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package world
+
+import (
+	"fmt"
+	"github.com/jnb666/gogp/gp"
+)
+
+// An Action is the value of an expression built from the opcodes in this package. It is called
+// to run the program for an agent and returns a sensor value.
+type Action func(a *Agent) int
+
+// Standard sensors and actuators. Actuators return the value of the cell where the agent ends up.
+var (
+	X        = Terminal("x", func(a *Agent) int { return a.Col })
+	Y        = Terminal("y", func(a *Agent) int { return a.Row })
+	Carrying = Terminal("carrying", func(a *Agent) int { return a.Carrying })
+	Here     = Terminal("here", func(a *Agent) int { return a.Here() })
+	Ahead    = Terminal("ahead", func(a *Agent) int { return a.Ahead() })
+	Step     = Terminal("step", func(a *Agent) int { a.Forward(); return a.Here() })
+	Left     = Terminal("left", func(a *Agent) int { a.Turn(-1); return a.Here() })
+	Right    = Terminal("right", func(a *Agent) int { a.Turn(1); return a.Here() })
+	GoRandom = Terminal("go-rand", func(a *Agent) int { a.Move(a.world.Rand.Intn(4)); return a.Here() })
+	PickUp   = Terminal("pickup", func(a *Agent) int { a.PickUp(); return a.Here() })
+	Prog2    = ProgN(2)
+	Prog3    = ProgN(3)
+	IfLTE    = If("iflte", 4, func(a *Agent, args []Action) bool { return args[0](a) <= args[1](a) })
+	IfLTZ    = If("ifltz", 3, func(a *Agent, args []Action) bool { return args[0](a) < 0 })
+	IfDrop   = If("ifdrop", 2, ifDrop)
+)
+
+// drop the item being carried if possible, true if carrying an item and the cell is empty even if
+// the agent has no moves left
+func ifDrop(a *Agent, args []Action) bool {
+	if a.Carrying == Empty || a.Here() != Empty {
+		return false
+	}
+	a.Drop()
+	return true
+}
+
+// Terminal returns an opcode which calls fn.
+func Terminal(name string, fn func(a *Agent) int) gp.Opcode {
+	return terminal{gp.Terminal(name), fn}
+}
+
+type terminal struct {
+	gp.Opcode
+	fn Action
+}
+
+func (o terminal) Eval(args ...gp.Value) gp.Value {
+	return o.fn
+}
+
+// Go returns a terminal which moves the agent in the given direction.
+func Go(name string, dir int) gp.Opcode {
+	return Terminal(name, func(a *Agent) int { a.Move(dir); return a.Here() })
+}
+
+// ProgN returns a function which calls each of its n arguments in turn and returns the last value.
+func ProgN(n int) gp.Opcode {
+	return progN{gp.Function(fmt.Sprintf("prog%d", n), n)}
+}
+
+type progN struct{ gp.Opcode }
+
+func (o progN) Eval(args ...gp.Value) gp.Value {
+	return Action(func(a *Agent) int {
+		value := 0
+		for _, arg := range args {
+			value = arg.(Action)(a)
+		}
+		return value
+	})
+}
+
+// If returns a function with the given arity which calls the second last argument if cond is
+// true, or else the last argument. The other arguments are passed to cond.
+func If(name string, arity int, cond func(a *Agent, args []Action) bool) gp.Opcode {
+	return ifElse{gp.Function(name, arity), cond}
+}
+
+// IfAhead returns a function which calls its first argument if the next cell in the direction
+// the agent is facing has the given value, or else the second argument.
+func IfAhead(name string, value int) gp.Opcode {
+	return If(name, 2, func(a *Agent, args []Action) bool { return a.Ahead() == value })
+}
+
+type ifElse struct {
+	gp.Opcode
+	cond func(*Agent, []Action) bool
+}
+
+func (o ifElse) Eval(iargs ...gp.Value) gp.Value {
+	args := make([]Action, len(iargs))
+	for i, arg := range iargs {
+		args[i] = arg.(Action)
+	}
+	n := len(args)
+	return Action(func(a *Agent) int {
+		if o.cond(a, args[:n-2]) {
+			return args[n-2](a)
+		}
+		return args[n-1](a)
+	})
+}
@@ -0,0 +1,306 @@
+// Package world provides a simulated grid world for agent based problems such as the artificial
+// ant. A Grid holds the initial state: a toroidal grid of cells, each of which may contain an
+// item, and the start positions of a number of agents. Each evaluation runs in a new World with
+// a copy of the cells and a random number generator with a fixed seed, so that the fitness is
+// deterministic. Agents have a position, direction and budget of moves, and the moves can be
+// recorded in the format used by util.Plot.AnimateMulti.
+package world
+
+import (
+	"bufio"
+	"github.com/jnb666/gogp/util"
+	"math/rand"
+)
+
+// Value for an empty cell or an agent which is not carrying anything.
+const Empty = 0
+
+// Offsets for each direction. Directions are numbered from 0 to 3, with 0 for increasing row
+// and 1 for increasing column.
+var (
+	DRow = []int{1, 0, -1, 0}
+	DCol = []int{0, 1, 0, -1}
+)
+
+// A Grid defines the starting state. Cells are indexed by row then column and non zero values
+// are items. Each agent is given its start position and direction. MaxMoves is the budget of
+// moves for each agent and Seed is used for the random number generator for each run. If Enter
+// is set it is called each time an agent moves to a new cell, e.g. to eat the food there.
+type Grid struct {
+	Rows, Cols int
+	Cells      [][]int
+	Start      []Agent
+	MaxMoves   int
+	Seed       int64
+	Enter      func(a *Agent)
+}
+
+// NewGrid creates an empty grid.
+func NewGrid(rows, cols, maxMoves int) *Grid {
+	g := &Grid{Rows: rows, Cols: cols, MaxMoves: maxMoves, Seed: 1}
+	g.Cells = make([][]int, rows)
+	for row := range g.Cells {
+		g.Cells[row] = make([]int, cols)
+	}
+	return g
+}
+
+// Read creates a grid from the lines read from the scanner. The cell function returns the
+// value for each character and true if an agent starts in that cell facing in direction 1.
+func Read(s *bufio.Scanner, maxMoves int, cell func(ch byte) (value int, agent bool)) *Grid {
+	g := &Grid{MaxMoves: maxMoves, Seed: 1}
+	for s.Scan() {
+		line := s.Bytes()
+		row := make([]int, len(line))
+		for col, ch := range line {
+			var agent bool
+			row[col], agent = cell(ch)
+			if agent {
+				g.AddAgent(len(g.Cells), col, 1)
+			}
+		}
+		g.Cells = append(g.Cells, row)
+		g.Cols = len(line)
+	}
+	g.Rows = len(g.Cells)
+	return g
+}
+
+// AddAgent adds an agent which starts at the given position.
+func (g *Grid) AddAgent(row, col, dir int) {
+	g.Start = append(g.Start, Agent{Id: len(g.Start), Row: row, Col: col, Dir: dir})
+}
+
+// Count returns the number of cells which contain the given value.
+func (g *Grid) Count(value int) int {
+	count := 0
+	for _, line := range g.Cells {
+		for _, v := range line {
+			if v == value {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Next returns the position of the cell next to row, col in direction dir, wrapping around at
+// the edges of the grid.
+func (g *Grid) Next(row, col, dir int) (nRow, nCol int) {
+	return util.Mod(row+DRow[dir], g.Rows), util.Mod(col+DCol[dir], g.Cols)
+}
+
+// A World holds the state for one run, with a copy of the grid cells which is updated as the
+// agents move. Each step of the recorded Path lists the events as
+// [agent id, column, row, code] where the code is zero if the agent moved, the value of the item
+// if it was picked up, or minus the value if it was dropped.
+type World struct {
+	*Grid
+	Cells  [][]int
+	Agents []*Agent
+	Rand   *rand.Rand
+	Path   [][][4]int
+	record bool
+	events [][4]int
+}
+
+// New creates a new world with a copy of the cells and agents at their start positions. If
+// record is set then the path of each agent is saved.
+func (g *Grid) New(record bool) *World {
+	w := &World{Grid: g, Rand: rand.New(rand.NewSource(g.Seed)), record: record}
+	w.Cells = make([][]int, g.Rows)
+	for row, line := range g.Cells {
+		w.Cells[row] = append([]int{}, line...)
+	}
+	for _, start := range g.Start {
+		a := start
+		a.Carrying, a.world = Empty, w
+		if record {
+			a.Path = [][2]int{{a.Col, a.Row}}
+		}
+		w.Agents = append(w.Agents, &a)
+	}
+	return w
+}
+
+// Run calls fn, e.g. the value of an evaluated expression, for each agent which has moves
+// remaining in turn, for the given number of steps.
+// If steps is zero it runs until all of the agents have used their moves, or until a step in
+// which no agent used a move, e.g. if fn only reads the sensors.
+func (w *World) Run(fn Action, steps int) {
+	for step := 0; step < steps || steps <= 0; step++ {
+		done, moved := true, false
+		for _, a := range w.Agents {
+			if !a.Done() {
+				moves := a.Moves
+				fn(a)
+				done, moved = false, moved || a.Moves != moves
+			}
+		}
+		w.EndStep()
+		if done || (steps <= 0 && !moved) {
+			break
+		}
+	}
+}
+
+// EndStep adds the events since the last call to the path if recording is enabled.
+func (w *World) EndStep() {
+	if len(w.events) > 0 {
+		w.Path = append(w.Path, w.events)
+		w.events = nil
+	}
+}
+
+// Get returns the value of the cell at the given position.
+func (w *World) Get(row, col int) int {
+	return w.Cells[row][col]
+}
+
+// Set updates the value of the cell at the given position.
+func (w *World) Set(row, col, value int) {
+	w.Cells[row][col] = value
+}
+
+// Occupied returns true if there is an agent at the given position.
+func (w *World) Occupied(row, col int) bool {
+	for _, a := range w.Agents {
+		if a.Row == row && a.Col == col {
+			return true
+		}
+	}
+	return false
+}
+
+// Record adds an event to the path for the current step.
+func (w *World) Record(id, row, col, code int) {
+	if w.record {
+		w.events = append(w.events, [4]int{id, col, row, code})
+	}
+}
+
+// Format returns the grid as text with a line for each row. The char function returns the
+// character for each cell given its value and whether there is an agent there.
+func (w *World) Format(char func(value int, agent bool) byte) string {
+	text := ""
+	for row, line := range w.Cells {
+		for col, value := range line {
+			text += string(char(value, w.Occupied(row, col)))
+		}
+		text += "\n"
+	}
+	return text
+}
+
+// Score returns the sum of the scores of the agents.
+func (w *World) Score() int {
+	score := 0
+	for _, a := range w.Agents {
+		score += a.Score
+	}
+	return score
+}
+
+// An Agent has a position and direction, the item it is carrying and the position where it was
+// picked up, the number of moves it has made and a score which can be updated by the problem,
+// e.g. the amount of food eaten. If the world is being recorded then Path has each position visited.
+type Agent struct {
+	Id, Row, Col, Dir    int
+	Carrying, Moves      int
+	PickupRow, PickupCol int
+	Score                int
+	Path                 [][2]int
+	world                *World
+}
+
+// World returns the world which the agent is in.
+func (a *Agent) World() *World {
+	return a.world
+}
+
+// Done returns true if the agent has used all of its moves.
+func (a *Agent) Done() bool {
+	return a.Moves >= a.world.MaxMoves
+}
+
+// Here returns the value of the cell where the agent is.
+func (a *Agent) Here() int {
+	return a.world.Cells[a.Row][a.Col]
+}
+
+// Ahead returns the value of the next cell in the direction the agent is facing.
+func (a *Agent) Ahead() int {
+	row, col := a.world.Next(a.Row, a.Col, a.Dir)
+	return a.world.Cells[row][col]
+}
+
+// Turn rotates the agent by adding n to its direction, where n may be negative. Uses one move.
+func (a *Agent) Turn(n int) {
+	if !a.Done() {
+		a.Moves++
+		a.Dir = util.Mod(a.Dir+n, 4)
+	}
+}
+
+// Forward moves the agent in the direction it is facing. Returns false if there are no moves
+// remaining or the cell is occupied by another agent, which uses a move.
+func (a *Agent) Forward() bool {
+	return a.Move(a.Dir)
+}
+
+// Move moves the agent in the given direction without changing the direction it is facing.
+// Returns false if there are no moves remaining or the cell is occupied by another agent, which
+// uses a move.
+func (a *Agent) Move(dir int) bool {
+	if a.Done() {
+		return false
+	}
+	a.Moves++
+	row, col := a.world.Next(a.Row, a.Col, dir)
+	if a.world.Occupied(row, col) {
+		return false
+	}
+	a.Row, a.Col = row, col
+	a.world.Record(a.Id, row, col, 0)
+	if a.Path != nil {
+		a.Path = append(a.Path, [2]int{col, row})
+	}
+	if a.world.Enter != nil {
+		a.world.Enter(a)
+	}
+	return true
+}
+
+// PickUp picks up the item in the current cell if the agent is not carrying anything. Returns
+// true and uses a move if successful.
+func (a *Agent) PickUp() bool {
+	value := a.Here()
+	if a.Done() || a.Carrying != Empty || value == Empty {
+		return false
+	}
+	a.Moves++
+	a.Carrying = value
+	a.PickupRow, a.PickupCol = a.Row, a.Col
+	a.world.Cells[a.Row][a.Col] = Empty
+	a.world.Record(a.Id, a.Row, a.Col, value)
+	return true
+}
+
+// Drop drops the item being carried if the current cell is empty. Returns true and uses a move
+// if successful.
+func (a *Agent) Drop() bool {
+	if a.Done() || a.Carrying == Empty || a.Here() != Empty {
+		return false
+	}
+	a.Moves++
+	a.DropAt(a.Row, a.Col)
+	return true
+}
+
+// DropAt puts the item being carried in the given cell without using a move, e.g. so that it
+// can be counted at the end of a run.
+func (a *Agent) DropAt(row, col int) {
+	a.world.Cells[row][col] = a.Carrying
+	a.world.Record(a.Id, row, col, -a.Carrying)
+	a.Carrying = Empty
+}
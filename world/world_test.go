@@ -0,0 +1,128 @@
+package world
+
+import (
+	"bufio"
+	"github.com/jnb666/gogp/gp"
+	"strings"
+	"testing"
+)
+
+const testGrid = `
+.#..
+.a##
+b...`
+
+func readGrid(t *testing.T) *Grid {
+	s := bufio.NewScanner(strings.NewReader(testGrid[1:]))
+	g := Read(s, 10, func(ch byte) (int, bool) {
+		switch ch {
+		case '#':
+			return 1, false
+		case 'a', 'b':
+			return Empty, true
+		}
+		return Empty, false
+	})
+	if g.Rows != 3 || g.Cols != 4 || len(g.Start) != 2 || g.Count(1) != 3 {
+		t.Fatalf("error reading grid: %+v", g)
+	}
+	return g
+}
+
+func TestAgent(t *testing.T) {
+	g := readGrid(t)
+	g.Enter = func(a *Agent) {
+		if a.Here() == 1 {
+			a.Score++
+		}
+	}
+	w := g.New(true)
+	a, b := w.Agents[0], w.Agents[1]
+	if a.Row != 1 || a.Col != 1 || a.Dir != 1 || a.Ahead() != 1 || b.Row != 2 || b.Col != 0 {
+		t.Fatalf("agents in wrong position: %+v %+v", a, b)
+	}
+	// wraps around to column 0 then blocked by other agent
+	for i := 0; i < 3; i++ {
+		a.Forward()
+	}
+	if a.Move(0) || a.Row != 1 || a.Col != 0 || a.Score != 2 || a.Moves != 4 {
+		t.Errorf("error moving forward: %+v", a)
+	}
+	if !b.Move(1) || b.Row != 2 || b.Col != 1 {
+		t.Errorf("error moving second agent: %+v", b)
+	}
+	w.EndStep()
+	// pick up and drop an item
+	a.Move(2)
+	a.Move(1)
+	if !a.PickUp() || a.Carrying != 1 || a.Here() != Empty || a.PickUp() || a.Moves != 7 ||
+		a.PickupRow != a.Row || a.PickupCol != a.Col {
+		t.Errorf("error picking up: %+v", a)
+	}
+	if !a.Move(1) || !a.Drop() || a.Carrying != Empty || a.Here() != 1 || a.Moves != 9 {
+		t.Errorf("error dropping: %+v", a)
+	}
+	w.EndStep()
+	a.Turn(1)
+	if !a.Done() || a.Forward() || a.Dir != 2 {
+		t.Errorf("expecting agent to be done: %+v", a)
+	}
+	t.Logf("path: %v", w.Path)
+	t.Logf("grid:\n%s", w.Format(func(value int, agent bool) byte {
+		if agent {
+			return '@'
+		}
+		return ".#"[value]
+	}))
+	if len(w.Path) != 2 || len(w.Path[0]) != 4 || w.Path[1][2] != [4]int{0, 1, 0, 1} || w.Path[1][4] != [4]int{0, 2, 0, -1} {
+		t.Errorf("invalid path")
+	}
+	if len(a.Path) != 7 || w.Score() != 3 || w.Get(0, 1) != Empty || g.Count(1) != 3 {
+		t.Errorf("invalid state: path=%v score=%d", a.Path, w.Score())
+	}
+}
+
+func TestRun(t *testing.T) {
+	g := readGrid(t)
+	pset := gp.CreatePrimSet(0)
+	pset.Add(Prog2, Prog3, IfAhead("if_item", 1), IfLTE, IfLTZ, IfDrop, X, Y, Carrying, Here, Ahead,
+		Step, Left, Right, GoRandom, PickUp, Go("go-n", 0))
+	code := gp.Expr{Prog3, IfAhead("if_item", 1), Step, Right, PickUp, GoRandom}
+	if code.Format() != "prog3(if_item(step, right), pickup, go-rand)" {
+		t.Errorf("format error: %s", code.Format())
+	}
+	run := func() *World {
+		w := g.New(false)
+		w.Run(code.Eval().(Action), 0)
+		return w
+	}
+	w := run()
+	for _, a := range w.Agents {
+		t.Logf("agent %d: %+v", a.Id, *a)
+		if !a.Done() {
+			t.Errorf("expecting agent %d to be done", a.Id)
+		}
+	}
+	grid := func(w *World) string {
+		return w.Format(func(value int, agent bool) byte { return ".#"[value] })
+	}
+	t.Logf("grid:\n%s", grid(w))
+	if w2 := run(); grid(w2) != grid(w) || w2.Agents[0].Carrying != w.Agents[0].Carrying {
+		t.Error("expecting run to be deterministic")
+	}
+	// a program which only reads the sensors uses no moves so should stop after one step
+	sensor := gp.Expr{IfLTZ, X, Here, Ahead}
+	w = g.New(false)
+	w.Run(sensor.Eval().(Action), 0)
+	for _, a := range w.Agents {
+		if a.Moves != 0 {
+			t.Errorf("expecting no moves for agent %d", a.Id)
+		}
+	}
+	for _, gen := range []gp.Generator{gp.GenFull(pset, 1, 4), gp.GenGrow(pset, 1, 4)} {
+		for i := 0; i < 20; i++ {
+			w := g.New(false)
+			w.Run(gen.Generate().Code.Eval().(Action), 20)
+		}
+	}
+}